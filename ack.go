@@ -0,0 +1,93 @@
+package librato
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+// AckLog records the IDs of batches accepted by the Librato API, so that a batch is
+// never posted twice, e.g. when a spooled batch that was also sent before an ambiguous
+// failure is replayed. See WithAckLog().
+type AckLog interface {
+	// Acked reports whether the batch with the given ID was accepted before.
+	Acked(id string) bool
+	// Ack records that the batch with the given ID was accepted.
+	Ack(id string) error
+}
+
+// FileAckLog is an AckLog that keeps batch IDs in memory and appends them to a file,
+// one per line, so that they survive restarts.
+type FileAckLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	ids map[string]struct{}
+}
+
+// NewFileAckLog opens (or creates) the ack log at path, loading the IDs already in it.
+func NewFileAckLog(path string) (*FileAckLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &FileAckLog{f: f, ids: make(map[string]struct{})}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if id := strings.TrimSpace(s.Text()); id != "" {
+			l.ids[id] = struct{}{}
+		}
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileAckLog) Acked(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.ids[id]
+	return ok
+}
+
+func (l *FileAckLog) Ack(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.ids[id]; ok {
+		return nil
+	}
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	l.ids[id] = struct{}{}
+	_, err := l.f.WriteString(id + "\n")
+	return err
+}
+
+// Close closes the underlying file. Acks recorded after Close will fail.
+func (l *FileAckLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// newBatchID returns a random batch ID.
+func newBatchID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,140 @@
+package librato
+
+import "encoding/json"
+
+// sumCounters sums counter values of the same name, source and tags into a single
+// measurement with the latest measure_time, keeping them in order of first appearance.
+// Integer values stay integers unless they're mixed with floats. Measurements pushed
+// with a Delivery and non-numeric values are left alone.
+func sumCounters(ms []Measurement) []Measurement {
+	type sum struct {
+		index int // in out
+		ints  int64
+		float float64
+		isInt bool
+	}
+
+	out := ms[:0:0]
+	sums := make(map[string]*sum)
+	for _, m := range ms {
+		f, ok := toFloat64(m.Value)
+		if !ok || m.ack != nil {
+			out = append(out, m)
+			continue
+		}
+		i, isInt := integer(m.Value)
+
+		key := seriesKey(&m)
+		s, ok := sums[key]
+		if !ok {
+			sums[key] = &sum{index: len(out), ints: i, float: f, isInt: isInt}
+			out = append(out, m)
+			continue
+		}
+
+		s.ints += i
+		s.float += f
+		s.isInt = s.isInt && isInt
+		agg := &out[s.index]
+		if m.MeasureTime > agg.MeasureTime {
+			agg.MeasureTime = m.MeasureTime
+		}
+	}
+
+	for _, s := range sums {
+		if s.isInt {
+			out[s.index].Value = s.ints
+		} else {
+			out[s.index].Value = s.float
+		}
+	}
+	return out
+}
+
+// integer returns v as an int64 if it's of an integer type.
+func integer(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float32, float64:
+		return 0, false
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return toInt64(v)
+}
+
+// dedup keeps only the last of measurements with the same name, source, tags and
+// measure_time, at the position of the first one.
+func dedup(ms []Measurement) []Measurement {
+	type key struct {
+		series string
+		time   int64
+	}
+
+	out := ms[:0:0]
+	index := make(map[key]int, len(ms))
+	for _, m := range ms {
+		k := key{seriesKey(&m), m.MeasureTime}
+		if i, ok := index[k]; ok {
+			out[i] = m
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
+
+// gaugeSummary summarizes the observations of one or more gauges, see summarize().
+type gaugeSummary struct {
+	count                     int64
+	sum, sumSquares, min, max float64
+	// noSumSquares is set if a pre-aggregated gauge without a sum of squares was merged,
+	// in which case sumSquares is meaningless.
+	noSumSquares bool
+}
+
+// summarize returns the summary of a plain or pre-aggregated gauge. It returns false if
+// the gauge can't be summarized, including pre-aggregated gauges with a count of 0 or
+// less, whose min and max would be NaN.
+func summarize(m *Measurement) (gaugeSummary, bool) {
+	if m.Count == nil || m.Sum == nil {
+		v, ok := toFloat64(m.Value)
+		if !ok {
+			return gaugeSummary{}, false
+		}
+		return gaugeSummary{count: 1, sum: v, sumSquares: v * v, min: v, max: v}, true
+	}
+	if *m.Count <= 0 {
+		return gaugeSummary{}, false
+	}
+
+	mean := *m.Sum / float64(*m.Count)
+	s := gaugeSummary{count: *m.Count, sum: *m.Sum, min: mean, max: mean}
+	if m.Min != nil {
+		s.min = *m.Min
+	}
+	if m.Max != nil {
+		s.max = *m.Max
+	}
+	if m.SumSquares != nil {
+		s.sumSquares = *m.SumSquares
+	} else {
+		s.noSumSquares = true
+	}
+	return s, true
+}
+
+// merge adds the observations of o to s.
+func (s *gaugeSummary) merge(o gaugeSummary) {
+	if s.count == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	if s.count == 0 || o.max > s.max {
+		s.max = o.max
+	}
+	s.count += o.count
+	s.sum += o.sum
+	s.sumSquares += o.sumSquares
+	s.noSumSquares = s.noSumSquares || o.noSumSquares
+}
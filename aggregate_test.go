@@ -0,0 +1,37 @@
+package librato
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int64) *int64 { return &v }
+
+	if _, ok := summarize(&Measurement{Count: n(0), Sum: f(0)}); ok {
+		t.Error("summarized a pre-aggregated gauge with a count of 0")
+	}
+	if _, ok := summarize(&Measurement{Value: "x"}); ok {
+		t.Error("summarized a non-numeric gauge")
+	}
+
+	var s gaugeSummary
+	for _, m := range []Measurement{
+		{Value: 4.0},
+		{Count: n(2), Sum: f(10), Min: f(1), Max: f(9), SumSquares: f(82)},
+	} {
+		ms, ok := summarize(&m)
+		if !ok {
+			t.Fatalf("couldn't summarize %+v", m)
+		}
+		s.merge(ms)
+	}
+	want := gaugeSummary{count: 3, sum: 14, sumSquares: 98, min: 1, max: 9}
+	if s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	ms, _ := summarize(&Measurement{Count: n(2), Sum: f(4)})
+	s.merge(ms)
+	if !s.noSumSquares || s.min != 1 || s.max != 9 {
+		t.Errorf("after merging a gauge without sum of squares: %+v", s)
+	}
+}
@@ -0,0 +1,159 @@
+package librato
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Aggregator aggregates the values pushed for a metric between flushes, instead of each
+// value being sent on its own. See SetGaugeAggregator().
+type Aggregator interface {
+	// Add is called with every value pushed for the metric, as it was passed to
+	// PushGauge(), PushCounter() or the metric's channel.
+	Add(value interface{})
+	// Flush is called at every flush and returns the measurements to send, if any.
+	// It should reset whatever state only applies to a single interval. Measurements
+	// without a name get the metric's name, and names starting with "." are appended
+	// to it, e.g. ".max".
+	Flush() []Measurement
+}
+
+// aggregatorKey identifies the metric of an aggregator.
+type aggregatorKey struct {
+	kind MetricKind
+	name string
+}
+
+// aggregator serializes the calls to an Aggregator.
+type aggregator struct {
+	mu  sync.Mutex
+	key aggregatorKey
+	agg Aggregator
+}
+
+// aggregators is a copy on write map of aggregators, so that pushes can look them up
+// without taking a lock.
+type aggregators struct {
+	mu sync.Mutex
+	m  atomic.Pointer[map[aggregatorKey]*aggregator]
+}
+
+// SetGaugeAggregator sets the Aggregator of the named gauge, replacing the previous one.
+// Values pushed for the gauge are passed to agg.Add(), and whatever agg.Flush() returns
+// is sent at every flush, named as described by Aggregator.Flush(). The Kind of each one
+// decides whether it's sent as a gauge or a counter. Then they go through
+// the same rename rules, prefix, validation and so on as pushed values.
+// Calls to agg are serialized. A nil agg removes the aggregator, without flushing it.
+func (c *TimeCollatedClient) SetGaugeAggregator(name string, agg Aggregator) {
+	c.aggregators.set(aggregatorKey{KindGauge, name}, agg)
+}
+
+// SetCounterAggregator sets the Aggregator of the named counter. See SetGaugeAggregator().
+func (c *TimeCollatedClient) SetCounterAggregator(name string, agg Aggregator) {
+	c.aggregators.set(aggregatorKey{KindCounter, name}, agg)
+}
+
+func (a *aggregators) set(key aggregatorKey, agg Aggregator) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.setLocked(key, agg)
+}
+
+func (a *aggregators) setLocked(key aggregatorKey, agg Aggregator) {
+	m := make(map[aggregatorKey]*aggregator)
+	if old := a.m.Load(); old != nil {
+		for k, v := range *old {
+			m[k] = v
+		}
+	}
+	if agg == nil {
+		delete(m, key)
+	} else {
+		m[key] = &aggregator{key: key, agg: agg}
+	}
+	a.m.Store(&m)
+}
+
+// aggregatorOf returns the aggregator of key if it's a T, and otherwise replaces it with
+// the result of create(), e.g. for GetMinMax().
+func aggregatorOf[T Aggregator](a *aggregators, key aggregatorKey, create func() T) T {
+	if cur := a.get(key); cur != nil {
+		if t, ok := cur.agg.(T); ok {
+			return t
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if cur := a.get(key); cur != nil {
+		if t, ok := cur.agg.(T); ok {
+			return t
+		}
+	}
+	t := create()
+	a.setLocked(key, t)
+	return t
+}
+
+// get returns the aggregator of key, or nil.
+func (a *aggregators) get(key aggregatorKey) *aggregator {
+	m := a.m.Load()
+	if m == nil {
+		return nil
+	}
+	return (*m)[key]
+}
+
+// has reports whether the metric has an aggregator.
+func (a *aggregators) has(kind MetricKind, name string) bool {
+	return a.get(aggregatorKey{kind, name}) != nil
+}
+
+// aggregate passes a pushed value to the metric's aggregator, and reports whether there
+// is one. Consumed values no longer count towards WithBackpressure().
+func (c *TimeCollatedClient) aggregate(kind MetricKind, name string, value interface{}) bool {
+	a := c.aggregators.get(aggregatorKey{kind, name})
+	if a == nil {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.agg.Add(value)
+	c.pressure.release(1)
+	return true
+}
+
+// aggregatorMeasurements flushes every aggregator and returns the prepared results.
+func (c *TimeCollatedClient) aggregatorMeasurements() []Measurement {
+	m := c.aggregators.m.Load()
+	if m == nil {
+		return nil
+	}
+
+	var out []Measurement
+	for _, a := range *m {
+		for _, ms := range a.flush() {
+			if ms.Name == "" || strings.HasPrefix(ms.Name, ".") {
+				ms.Name = a.key.name + ms.Name
+			}
+			if ms.Source == "" {
+				ms.Source = *c.source.Load()
+			}
+			if ms.MeasureTime == 0 {
+				ms.MeasureTime = c.clock.Now().Unix()
+			}
+			if c.prepare(&ms) {
+				out = append(out, ms)
+			}
+		}
+	}
+	return out
+}
+
+func (a *aggregator) flush() []Measurement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.agg.Flush()
+}
@@ -0,0 +1,96 @@
+package librato
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Alert is an alert definition.
+// http://api-docs-archive.librato.com/#alerts
+type Alert struct {
+	ID          int              `json:"id,omitempty" yaml:"-"`
+	Name        string           `json:"name" yaml:"name"`
+	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Conditions  []AlertCondition `json:"conditions" yaml:"conditions"`
+	// Services are the IDs of the services notified when the alert triggers.
+	Services []int `json:"services,omitempty" yaml:"services,omitempty"`
+	// Attributes are e.g. {"runbook_url": "..."}.
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	// Active defaults to true if it's nil.
+	Active *bool `json:"active,omitempty" yaml:"active,omitempty"`
+	// RearmSeconds is the minimum time between notifications, 600 by default.
+	RearmSeconds int `json:"rearm_seconds,omitempty" yaml:"rearm_seconds,omitempty"`
+}
+
+// AlertCondition is a condition that triggers an alert.
+type AlertCondition struct {
+	// Type is "above", "below" or "absent".
+	Type       string `json:"type" yaml:"type"`
+	MetricName string `json:"metric_name" yaml:"metric_name"`
+	Source     string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Threshold is nil for "absent" conditions, so that 0 is still sent.
+	Threshold *float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	// SummaryFunction is e.g. "average", "sum", "min", "max" or "count".
+	SummaryFunction string `json:"summary_function,omitempty" yaml:"summary_function,omitempty"`
+	// Duration is how long the condition must hold, in seconds.
+	Duration int `json:"duration,omitempty" yaml:"duration,omitempty"`
+}
+
+// UnmarshalJSON accepts services as IDs, as they're sent, or as the service
+// objects returned by the API.
+func (a *Alert) UnmarshalJSON(data []byte) error {
+	type alert Alert
+	var raw struct {
+		*alert
+		Services []json.RawMessage `json:"services"`
+	}
+	raw.alert = (*alert)(a)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	a.Services = nil
+	for _, s := range raw.Services {
+		var id int
+		if err := json.Unmarshal(s, &id); err != nil {
+			var svc Service
+			if err := json.Unmarshal(s, &svc); err != nil {
+				return fmt.Errorf("invalid alert service %s", s)
+			}
+			id = svc.ID
+		}
+		a.Services = append(a.Services, id)
+	}
+	return nil
+}
+
+// ListAlerts returns a page of the account's alerts.
+func (c *TimeCollatedClient) ListAlerts(ctx context.Context, opts *ListOptions) ([]Alert, QueryInfo, error) {
+	return listPage[Alert](ctx, c, "/alerts", "alerts", opts.values())
+}
+
+// Alerts iterates over all of the account's alerts, starting at opts.Offset.
+func (c *TimeCollatedClient) Alerts(ctx context.Context, opts *ListOptions) *Iterator[Alert] {
+	return newIterator[Alert](ctx, c, "/alerts", "alerts", opts.values())
+}
+
+// CreateAlert creates an alert and returns it with its ID.
+func (c *TimeCollatedClient) CreateAlert(ctx context.Context, a *Alert) (*Alert, error) {
+	created := &Alert{}
+	if err := c.apiRequest(ctx, http.MethodPost, "/alerts", nil, a, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateAlert replaces the alert with a.ID.
+func (c *TimeCollatedClient) UpdateAlert(ctx context.Context, a *Alert) error {
+	return c.apiRequest(ctx, http.MethodPut, fmt.Sprintf("/alerts/%d", a.ID), nil, a, nil)
+}
+
+// DeleteAlert deletes the alert with the given ID.
+func (c *TimeCollatedClient) DeleteAlert(ctx context.Context, id int) error {
+	return c.apiRequest(ctx, http.MethodDelete, fmt.Sprintf("/alerts/%d", id), nil, nil, nil)
+}
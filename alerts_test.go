@@ -0,0 +1,26 @@
+package librato
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAlertConditionZeroThreshold(t *testing.T) {
+	zero := 0.0
+	b, err := json.Marshal(AlertCondition{Type: "below", MetricName: "queue.depth", Threshold: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"threshold":0`) {
+		t.Errorf("threshold of 0 dropped: %s", b)
+	}
+
+	b, err = json.Marshal(AlertCondition{Type: "absent", MetricName: "queue.depth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "threshold") {
+		t.Errorf("absent condition has a threshold: %s", b)
+	}
+}
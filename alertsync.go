@@ -0,0 +1,218 @@
+package librato
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// AlertDefinitions declares alerts, to be reviewed with PlanAlerts() and reconciled with
+// ApplyAlerts(), e.g. from a CI pipeline. Alerts are identified by their names.
+//
+// Fields left empty use Librato's defaults and aren't compared with live alerts, so an
+// alert is only updated when a field set in its definition differs.
+type AlertDefinitions struct {
+	Alerts []Alert `json:"alerts" yaml:"alerts"`
+	// Prune deletes alerts of the account that aren't declared. Otherwise they're left alone.
+	Prune bool `json:"prune,omitempty" yaml:"prune,omitempty"`
+}
+
+// LoadAlerts decodes alert definitions from JSON.
+func LoadAlerts(r io.Reader) (*AlertDefinitions, error) {
+	defs := &AlertDefinitions{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(defs); err != nil {
+		return nil, fmt.Errorf("librato: alerts: %w", err)
+	}
+	return defs, nil
+}
+
+// AlertChange is a change to a single alert, see AlertPlan.
+type AlertChange struct {
+	// Action is "create", "update" or "delete".
+	Action string
+	Name   string
+	// Diff lists the changed fields of updated alerts, e.g.
+	// `conditions[0].threshold: 90 -> 95`.
+	Diff []string
+
+	id  int
+	def *Alert
+}
+
+// AlertPlan is the set of changes needed to make the account's alerts match their
+// definitions. Its String method is a human-readable diff, meant for review.
+type AlertPlan struct {
+	Changes []AlertChange
+}
+
+// Empty reports whether the alerts already match their definitions.
+func (p *AlertPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+func (p *AlertPlan) String() string {
+	if p.Empty() {
+		return "No changes.\n"
+	}
+
+	var b strings.Builder
+	for _, ch := range p.Changes {
+		sign := map[string]string{"create": "+", "update": "~", "delete": "-"}[ch.Action]
+		fmt.Fprintf(&b, "%s alert %q\n", sign, ch.Name)
+		for _, d := range ch.Diff {
+			fmt.Fprintf(&b, "    %s\n", d)
+		}
+	}
+	return b.String()
+}
+
+// PlanAlerts compares the definitions with the account's alerts, without changing them.
+func (c *TimeCollatedClient) PlanAlerts(ctx context.Context, defs *AlertDefinitions) (*AlertPlan, error) {
+	names := make(map[string]bool, len(defs.Alerts))
+	for _, a := range defs.Alerts {
+		if a.Name == "" {
+			return nil, fmt.Errorf("librato: alerts: alert without a name")
+		}
+		if names[a.Name] {
+			return nil, fmt.Errorf("librato: alerts: duplicate alert %q", a.Name)
+		}
+		names[a.Name] = true
+	}
+
+	live := make(map[string]Alert)
+	it := c.Alerts(ctx, nil)
+	for it.Next() {
+		live[it.Value().Name] = it.Value()
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := &AlertPlan{}
+	for i := range defs.Alerts {
+		def := &defs.Alerts[i]
+		cur, ok := live[def.Name]
+		delete(live, def.Name)
+		if !ok {
+			plan.Changes = append(plan.Changes, AlertChange{Action: "create", Name: def.Name, def: def})
+			continue
+		}
+
+		diff, err := alertDiff(cur, *def)
+		if err != nil {
+			return nil, err
+		}
+		if len(diff) > 0 {
+			plan.Changes = append(plan.Changes, AlertChange{Action: "update", Name: def.Name, Diff: diff, id: cur.ID, def: def})
+		}
+	}
+
+	if defs.Prune {
+		deleted := make([]AlertChange, 0, len(live))
+		for name, a := range live {
+			deleted = append(deleted, AlertChange{Action: "delete", Name: name, id: a.ID})
+		}
+		sort.Slice(deleted, func(i, j int) bool { return deleted[i].Name < deleted[j].Name })
+		plan.Changes = append(plan.Changes, deleted...)
+	}
+	return plan, nil
+}
+
+// ApplyAlerts makes the account's alerts match the definitions. It's idempotent: once
+// applied, the plan of the same definitions is empty. It returns the plan it applied,
+// or the error of the first change that failed, in which case the changes before it
+// were already made.
+func (c *TimeCollatedClient) ApplyAlerts(ctx context.Context, defs *AlertDefinitions) (*AlertPlan, error) {
+	plan, err := c.PlanAlerts(ctx, defs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range plan.Changes {
+		switch ch.Action {
+		case "create":
+			_, err = c.CreateAlert(ctx, ch.def)
+		case "update":
+			a := *ch.def
+			a.ID = ch.id
+			err = c.UpdateAlert(ctx, &a)
+		case "delete":
+			err = c.DeleteAlert(ctx, ch.id)
+		}
+		if err != nil {
+			return plan, fmt.Errorf("librato: alerts: %s %q: %w", ch.Action, ch.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+// alertDiff lists the fields set in def that differ in live.
+func alertDiff(live, def Alert) ([]string, error) {
+	live.ID, def.ID = 0, 0
+	return jsonDiff(live, def)
+}
+
+// jsonDiff lists the fields set in the JSON encoding of def that differ in live.
+// Fields def leaves empty are skipped, so defaults filled in by the API don't count.
+func jsonDiff(live, def interface{}) ([]string, error) {
+	var l, d interface{}
+	for _, v := range []struct {
+		in  interface{}
+		out *interface{}
+	}{{live, &l}, {def, &d}} {
+		b, err := json.Marshal(v.in)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, v.out); err != nil {
+			return nil, err
+		}
+	}
+
+	var diff []string
+	diffValues("", l, d, &diff)
+	return diff, nil
+}
+
+// diffValues appends the differences between live and def to diff, recursing
+// into objects and arrays of the same length. Object keys missing from def
+// (empty fields) are skipped.
+func diffValues(path string, live, def interface{}, diff *[]string) {
+	switch d := def.(type) {
+	case map[string]interface{}:
+		if l, ok := live.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(d))
+			for k := range d {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				diffValues(p, l[k], d[k], diff)
+			}
+			return
+		}
+	case []interface{}:
+		if l, ok := live.([]interface{}); ok && len(l) == len(d) {
+			for i := range d {
+				diffValues(fmt.Sprintf("%s[%d]", path, i), l[i], d[i], diff)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(live, def) {
+		l, _ := json.Marshal(live)
+		d, _ := json.Marshal(def)
+		*diff = append(*diff, fmt.Sprintf("%s: %s -> %s", path, l, d))
+	}
+}
@@ -0,0 +1,128 @@
+// Package alertwebhook receives Librato alert notifications sent to webhook services.
+// http://api-docs-archive.librato.com/#services
+package alertwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits the size of accepted payloads.
+const maxBodySize = 1 << 20
+
+// Payload is an alert notification.
+type Payload struct {
+	Alert       Alert       `json:"alert"`
+	Account     string      `json:"account"`
+	TriggerTime int64       `json:"trigger_time"`
+	Conditions  []Condition `json:"conditions"`
+	// Violations are the measurements that triggered the alert, by source.
+	Violations map[string][]Violation `json:"violations"`
+	// Clear is set (e.g. to "normal", "auto" or "manual") if the alert was cleared.
+	Clear string `json:"clear"`
+}
+
+// Cleared reports whether the alert was cleared, rather than triggered.
+func (p *Payload) Cleared() bool {
+	return p.Clear != ""
+}
+
+// Time returns the time the alert was triggered or cleared.
+func (p *Payload) Time() time.Time {
+	return time.Unix(p.TriggerTime, 0)
+}
+
+type Alert struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	RunbookURL  string `json:"runbook_url"`
+	Version     int    `json:"version"`
+}
+
+type Condition struct {
+	ID int `json:"id"`
+	// Type is "above", "below" or "absent".
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold"`
+	Duration  int     `json:"duration"`
+}
+
+type Violation struct {
+	Metric     string  `json:"metric"`
+	Value      float64 `json:"value"`
+	RecordedAt int64   `json:"recorded_at"`
+	// ConditionViolated is the ID of the violated condition.
+	ConditionViolated int   `json:"condition_violated"`
+	Count             int   `json:"count"`
+	Begin             int64 `json:"begin"`
+	End               int64 `json:"end"`
+}
+
+// Handler is an http.Handler that parses alert notifications and passes them to
+// its callbacks. Payloads are accepted both as JSON bodies and as the "payload"
+// field of a form, which is how Librato sends them.
+type Handler struct {
+	// OnTrigger is called for triggered alerts and OnClear for cleared ones.
+	// Either may be nil. If they return an error, Librato is sent a 500 response.
+	OnTrigger func(r *http.Request, p *Payload) error
+	OnClear   func(r *http.Request, p *Payload) error
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := Parse(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fn := h.OnTrigger
+	if p.Cleared() {
+		fn = h.OnClear
+	}
+	if fn != nil {
+		if err := fn(r, p); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Parse reads an alert notification from a request.
+func Parse(r *http.Request) (*Payload, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBodySize)
+
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+		data = []byte(r.PostForm.Get("payload"))
+	} else {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	p := &Payload{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	return p, nil
+}
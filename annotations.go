@@ -0,0 +1,203 @@
+package librato
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PostDeployAnnotation records a deploy of version (and optionally the git commit it was
+// built from) to the given annotation stream, with the client's source. Links are attached
+// as they are, e.g. to the changelog or the CI build.
+func (c *TimeCollatedClient) PostDeployAnnotation(stream, version, gitSHA string, links ...Link) error {
+	title := fmt.Sprintf("Deployed %s", version)
+	description := fmt.Sprintf("version %s", version)
+	if gitSHA != "" {
+		description += fmt.Sprintf(", commit %s", gitSHA)
+	}
+	source := *c.source.Load()
+	start := c.clock.Now().Unix()
+
+	a := &Annotation{
+		Title:       title,
+		Description: &description,
+		Links:       links,
+		StartTime:   &start,
+	}
+	if source != "" {
+		a.Source = &source
+	}
+	return c.PostAnnotation(a, stream)
+}
+
+// lifecycle posts annotations when the process starts and stops, see WithLifecycleAnnotations().
+type lifecycle struct {
+	stream, version string
+	started         int64
+	once            sync.Once
+	// scheduled is set once the start annotation is handed to a poller, see postStartedAsync().
+	scheduled atomic.Bool
+}
+
+// postLifecycle posts a lifecycle annotation, reporting any error.
+func (c *TimeCollatedClient) postLifecycle(event string, at int64) {
+	l := c.lifecycle
+	hostname, _ := os.Hostname()
+	description := fmt.Sprintf("host %s, pid %d", hostname, os.Getpid())
+	if l.version != "" {
+		description += fmt.Sprintf(", version %s", l.version)
+	}
+	source := *c.source.Load()
+
+	a := &Annotation{
+		Title:       "Process " + event,
+		Description: &description,
+		StartTime:   &at,
+	}
+	if source != "" {
+		a.Source = &source
+	}
+	if err := c.PostAnnotation(a, l.stream); err != nil {
+		c.reportError(fmt.Errorf("lifecycle annotation: %w", err))
+	}
+}
+
+// postStarted posts the start annotation once. It's deferred to the first flush,
+// so that setters like SetEndpoint() can be called after the client is created.
+func (c *TimeCollatedClient) postStarted() {
+	if c.lifecycle != nil {
+		c.lifecycle.once.Do(func() {
+			c.postLifecycle("started", c.lifecycle.started)
+		})
+	}
+}
+
+// postStartedAsync posts the start annotation from a poller goroutine, so that a slow
+// annotations endpoint doesn't hold up the first flush. Once the client is closing,
+// Close() posts it instead.
+func (c *TimeCollatedClient) postStartedAsync() {
+	if c.lifecycle != nil && c.lifecycle.scheduled.CompareAndSwap(false, true) {
+		c.goPoller(c.postStarted)
+	}
+}
+
+// AnnotationBuilder builds an Annotation without having to deal with pointers,
+// validating it before it's submitted:
+//
+//	a, err := librato.NewAnnotationBuilder("Deployed v1.2.3").
+//		Source("web-1").
+//		Link("github", "https://github.com/org/repo/releases/v1.2.3", "").
+//		StartTime(start).
+//		Build()
+type AnnotationBuilder struct {
+	a Annotation
+}
+
+// NewAnnotationBuilder starts building an annotation with the given title.
+func NewAnnotationBuilder(title string) *AnnotationBuilder {
+	return &AnnotationBuilder{a: Annotation{Title: title}}
+}
+
+func (b *AnnotationBuilder) Title(title string) *AnnotationBuilder {
+	b.a.Title = title
+	return b
+}
+
+func (b *AnnotationBuilder) Source(source string) *AnnotationBuilder {
+	b.a.Source = &source
+	return b
+}
+
+func (b *AnnotationBuilder) Description(description string) *AnnotationBuilder {
+	b.a.Description = &description
+	return b
+}
+
+// Link adds a link. The label is optional.
+func (b *AnnotationBuilder) Link(rel, href, label string) *AnnotationBuilder {
+	l := Link{Relationship: rel, URL: href}
+	if label != "" {
+		l.Label = &label
+	}
+	b.a.Links = append(b.a.Links, l)
+	return b
+}
+
+func (b *AnnotationBuilder) StartTime(t time.Time) *AnnotationBuilder {
+	b.a.SetStart(t)
+	return b
+}
+
+func (b *AnnotationBuilder) EndTime(t time.Time) *AnnotationBuilder {
+	b.a.SetEnd(t)
+	return b
+}
+
+// Build validates the annotation and returns a copy of it. The title is required,
+// links need a rel and an absolute href, and the end time can't be before the start.
+func (b *AnnotationBuilder) Build() (*Annotation, error) {
+	var errs []error
+	if b.a.Title == "" {
+		errs = append(errs, errors.New("title is required"))
+	}
+	for i, l := range b.a.Links {
+		if l.Relationship == "" {
+			errs = append(errs, fmt.Errorf("link %d: rel is required", i))
+		}
+		if u, err := url.Parse(l.URL); err != nil || !u.IsAbs() {
+			errs = append(errs, fmt.Errorf("link %d: href %q is not an absolute URL", i, l.URL))
+		}
+	}
+	if b.a.StartTime != nil && b.a.EndTime != nil && *b.a.EndTime < *b.a.StartTime {
+		errs = append(errs, errors.New("end time is before start time"))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("librato: invalid annotation: %w", errors.Join(errs...))
+	}
+
+	a := b.a
+	a.Links = append([]Link(nil), b.a.Links...)
+	return &a, nil
+}
+
+// Post builds the annotation and posts it to stream with c.
+func (b *AnnotationBuilder) Post(c Client, stream string) error {
+	a, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return c.PostAnnotation(a, stream)
+}
+
+// SetStart sets the start time of the annotation.
+func (a *Annotation) SetStart(t time.Time) {
+	start := t.Unix()
+	a.StartTime = &start
+}
+
+// SetEnd sets the end time of the annotation.
+func (a *Annotation) SetEnd(t time.Time) {
+	end := t.Unix()
+	a.EndTime = &end
+}
+
+// Start returns the start time of the annotation, or the zero time if it's not set.
+func (a *Annotation) Start() time.Time {
+	return unixTime(a.StartTime)
+}
+
+// End returns the end time of the annotation, or the zero time if it's not set.
+func (a *Annotation) End() time.Time {
+	return unixTime(a.EndTime)
+}
+
+func unixTime(sec *int64) time.Time {
+	if sec == nil {
+		return time.Time{}
+	}
+	return time.Unix(*sec, 0)
+}
@@ -0,0 +1,208 @@
+package librato
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiRequest makes an authenticated request to path under the API endpoint, e.g. "/metrics".
+// in is encoded as the request body unless it's nil, and the response body is decoded into
+// out unless it's nil.
+func (c *TimeCollatedClient) apiRequest(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		buf, err := c.encode(in)
+		if err != nil {
+			return err
+		}
+		defer buf.Close()
+		body = buf
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	return c.do(req, out)
+}
+
+// requestContext applies the request timeout to ctx, see WithRequestTimeout().
+func (c *TimeCollatedClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.requestTimeout > 0 {
+		return context.WithTimeout(ctx, c.requestTimeout)
+	}
+	return ctx, func() {}
+}
+
+// newRequest creates an authenticated request. If body is an io.Closer,
+// it's closed once the request is done, even if it can't be created. An *encodeBuffer
+// is the exception: the request reads it through bodies that can be replayed, and its
+// owner closes it once the request is done.
+func (c *TimeCollatedClient) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	buf, pooled := body.(*encodeBuffer)
+	if pooled {
+		body = nil
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+	if pooled {
+		req.Body = buf.body()
+		req.GetBody = func() (io.ReadCloser, error) {
+			return buf.body(), nil
+		}
+		req.ContentLength = int64(buf.Len())
+		body = buf
+	}
+
+	if body != nil {
+		if l, ok := body.(interface{ Len() int }); ok && req.ContentLength == 0 {
+			req.ContentLength = int64(l.Len())
+		}
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	for k, vs := range c.header {
+		req.Header[k] = vs
+	}
+	req.SetBasicAuth(c.user, c.token)
+	return req, nil
+}
+
+// do sends a request and decodes the response body into out, unless it's nil.
+// Unsuccessful responses are returned as *APIError.
+func (c *TimeCollatedClient) do(req *http.Request, out interface{}) error {
+	release, err := c.acquire(req)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return err
+	}
+	defer release()
+
+	ctx, span := c.startSpan(req.Context(), SpanRequest)
+	defer span.End()
+	span.SetAttribute(AttrMethod, req.Method)
+	span.SetAttribute(AttrURL, req.URL.Redacted())
+
+	start := time.Now()
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		c.debugRequest(req, start, 0, "", err)
+		return err
+	}
+	defer res.Body.Close()
+	span.SetAttribute(AttrStatusCode, res.StatusCode)
+	c.recordResponse(req, res)
+
+	// Do not discard response body in case of Librato errors
+	// http://api-docs-archive.librato.com/#http-status-codes
+	if res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(res.Body)
+		err := &APIError{StatusCode: res.StatusCode, Body: strings.TrimSpace(string(b))}
+		span.RecordError(err)
+		c.debugRequest(req, start, res.StatusCode, err.Body, nil)
+		return err
+	}
+	c.debugRequest(req, start, res.StatusCode, "", nil)
+
+	if out != nil && res.StatusCode != http.StatusNoContent {
+		// Some endpoints respond with an empty body, which leaves out as it is.
+		if err := json.NewDecoder(res.Body).Decode(out); err != io.EOF {
+			return err
+		}
+		return nil
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// Ping makes a cheap authenticated request to the metrics API, to check credentials
+// and connectivity. It's meant to be called at startup, so that misconfigured tokens
+// fail fast instead of silently dropping data. Invalid credentials return an *APIError
+// with a 401 status code.
+func (c *TimeCollatedClient) Ping(ctx context.Context) error {
+	return c.apiRequest(ctx, http.MethodGet, "/metrics", url.Values{"length": {"1"}}, nil, nil)
+}
+
+// ListOptions controls the page returned by list endpoints.
+// http://api-docs-archive.librato.com/#pagination
+type ListOptions struct {
+	// Offset is the index of the first item, Length the maximum number
+	// of items (at most 100). Zero values use Librato's defaults.
+	Offset, Length int
+	// OrderBy and Sort ("asc" or "desc") order the items.
+	OrderBy, Sort string
+}
+
+func (o *ListOptions) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Length > 0 {
+		q.Set("length", strconv.Itoa(o.Length))
+	}
+	if o.OrderBy != "" {
+		q.Set("orderby", o.OrderBy)
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	return q
+}
+
+// QueryInfo describes the page returned by a list endpoint.
+type QueryInfo struct {
+	Found  int `json:"found"`
+	Length int `json:"length"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+// listPage gets a page of a list endpoint, whose items are in the key field of the response.
+func listPage[T any](ctx context.Context, c *TimeCollatedClient, path, key string, query url.Values) ([]T, QueryInfo, error) {
+	var res map[string]json.RawMessage
+	if err := c.apiRequest(ctx, http.MethodGet, path, query, nil, &res); err != nil {
+		return nil, QueryInfo{}, err
+	}
+
+	var info QueryInfo
+	if q, ok := res["query"]; ok {
+		if err := json.Unmarshal(q, &info); err != nil {
+			return nil, QueryInfo{}, err
+		}
+	}
+	var items []T
+	if raw, ok := res[key]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, QueryInfo{}, err
+		}
+	}
+	return items, info, nil
+}
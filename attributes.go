@@ -0,0 +1,199 @@
+package librato
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// attributesDelay is how long attribute updates are collected before they're sent,
+// so that repeated updates of the same metric are coalesced into one request.
+const attributesDelay = time.Second
+
+// GetGaugeWithAttributes is like GetGauge(), but also sets the display attributes of
+// the gauge, e.g. its units, so that charts show them without separate provisioning.
+// The attributes are sent in the background, once per name and client, or only if they
+// changed since they were last sent if there's an attribute cache. See WithAttributeCache().
+func (c *TimeCollatedClient) GetGaugeWithAttributes(name string, attrs MetricAttributes) Chan {
+	c.applyAttributes(KindGauge, name, attrs)
+	return c.GetGauge(name)
+}
+
+// GetCounterWithAttributes is like GetCounter(), but also sets the display attributes
+// of the counter. See GetGaugeWithAttributes().
+func (c *TimeCollatedClient) GetCounterWithAttributes(name string, attrs MetricAttributes) Chan {
+	c.applyAttributes(KindCounter, name, attrs)
+	return c.GetCounter(name)
+}
+
+// AttributeCache remembers which metric attributes were applied, so that they aren't
+// sent again every time a process starts. See WithAttributeCache().
+type AttributeCache interface {
+	// Applied reports whether attributes with the given hash were applied to the metric.
+	Applied(name, hash string) bool
+	// Store records that attributes with the given hash were applied to the metric.
+	Store(name, hash string) error
+}
+
+// FileAttributeCache is an AttributeCache that keeps applied attributes in memory and
+// appends them to a file, one "name hash" pair per line, so that they survive restarts.
+type FileAttributeCache struct {
+	mu     sync.Mutex
+	f      *os.File
+	hashes map[string]string
+}
+
+// NewFileAttributeCache opens (or creates) the cache file at path, loading its contents.
+func NewFileAttributeCache(path string) (*FileAttributeCache, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &FileAttributeCache{f: f, hashes: make(map[string]string)}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// Later lines win, as they're appended whenever attributes change.
+		if name, hash, ok := strings.Cut(strings.TrimSpace(s.Text()), " "); ok {
+			a.hashes[name] = hash
+		}
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *FileAttributeCache) Applied(name, hash string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.hashes[name] == hash
+}
+
+func (a *FileAttributeCache) Store(name, hash string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.hashes[name] == hash {
+		return nil
+	}
+	if a.f == nil {
+		return os.ErrClosed
+	}
+	a.hashes[name] = hash
+	_, err := a.f.WriteString(name + " " + hash + "\n")
+	return err
+}
+
+// Close closes the underlying file. Attributes stored after Close will fail.
+func (a *FileAttributeCache) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// attributeUpdates coalesces attribute updates until the worker sends them.
+type attributeUpdates struct {
+	// hashes are the hashes of the attributes sent or about to be sent, by metric name.
+	hashes  map[string]string
+	pending map[string]*Metric
+	running bool
+}
+
+// attributesHash returns a hash identifying attrs.
+func attributesHash(attrs *MetricAttributes) string {
+	b, _ := json.Marshal(attrs)
+	h := fnv.New64a()
+	h.Write(b)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// applyAttributes queues the attributes of a metric, unless they were already applied.
+// The name is renamed and prefixed as measurements of the metric would be.
+func (c *TimeCollatedClient) applyAttributes(kind MetricKind, name string, attrs MetricAttributes) {
+	m := Measurement{Kind: kind, Name: name, Source: *c.source.Load()}
+	for i := range c.renameRules {
+		c.renameRules[i].apply(&m)
+	}
+	name = c.prefix + m.Name
+	hash := attributesHash(&attrs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := &c.attributes
+	if u.hashes == nil {
+		u.hashes = make(map[string]string)
+		u.pending = make(map[string]*Metric)
+	}
+	if u.hashes[name] == hash {
+		return
+	}
+	u.hashes[name] = hash
+	if c.attributeCache != nil && c.attributeCache.Applied(name, hash) {
+		// Drop a queued update to other attributes, they were replaced again.
+		delete(u.pending, name)
+		return
+	}
+	u.pending[name] = &Metric{Name: name, Type: kind.String(), Attributes: &attrs}
+
+	if u.running {
+		return
+	}
+	u.running = c.goPollerLocked(c.sendAttributes)
+}
+
+// sendAttributes sends queued attribute updates, one request per metric, until there
+// are none left. Updates that fail are reported and retried the next time the
+// metric is retrieved. Updates still pending when the client is closed are sent right
+// away, within closeGrace.
+func (c *TimeCollatedClient) sendAttributes() {
+	ctx, cancel := c.closingContext()
+	defer cancel()
+	for {
+		t := c.clock.NewTicker(attributesDelay)
+		select {
+		case <-t.C():
+		case <-c.closing:
+		}
+		t.Stop()
+
+		c.mu.Lock()
+		u := &c.attributes
+		pending := u.pending
+		if len(pending) == 0 {
+			u.running = false
+			c.mu.Unlock()
+			return
+		}
+		u.pending = make(map[string]*Metric)
+		c.mu.Unlock()
+
+		for name, m := range pending {
+			err := c.UpdateMetric(ctx, m)
+			if err == nil && c.attributeCache != nil {
+				err = c.attributeCache.Store(name, attributesHash(m.Attributes))
+			}
+			if err != nil {
+				c.reportError(fmt.Errorf("attributes of %s: %w", name, err))
+				c.mu.Lock()
+				if _, queued := u.pending[name]; !queued {
+					delete(u.hashes, name)
+				}
+				c.mu.Unlock()
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package librato
+
+import (
+	"context"
+	"sync"
+)
+
+// backpressure counts measurements pushed but not yet flushed, and blocks
+// producers while there are too many of them. See WithBackpressure().
+// A nil *backpressure never blocks.
+type backpressure struct {
+	high int
+
+	mu     sync.Mutex
+	n      int
+	closed bool
+	// drained is closed and replaced whenever n drops, waking blocked producers.
+	drained chan struct{}
+}
+
+func newBackpressure(high int) *backpressure {
+	return &backpressure{high: high, drained: make(chan struct{})}
+}
+
+// acquire reserves room for one measurement, waiting until there is some or ctx is done.
+// Once the client is closed it returns ErrDropped, since the metric channels are closing.
+func (b *backpressure) acquire(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	for b.n >= b.high && !b.closed {
+		drained := b.drained
+		b.mu.Unlock()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		b.mu.Lock()
+	}
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrDropped
+	}
+	b.n++
+	return nil
+}
+
+// release frees the room of n measurements. Values pushed to metric channels
+// directly were never acquired, so the count is clamped at zero.
+func (b *backpressure) release(n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.n -= min(n, b.n)
+	close(b.drained)
+	b.drained = make(chan struct{})
+}
+
+// close wakes all blocked producers and stops blocking new ones.
+func (b *backpressure) close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.drained)
+	}
+}
@@ -0,0 +1,166 @@
+package librato
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OtherSeries is the name, source and tag value that series are collapsed into
+// once a cardinality limit is exceeded. See CardinalityCollapse.
+const OtherSeries = "other"
+
+// CardinalityPolicy decides what happens to new series once a limit is reached.
+// See WithCardinalityLimit().
+type CardinalityPolicy int
+
+const (
+	// CardinalityDrop drops measurements of new series. It's the default.
+	CardinalityDrop CardinalityPolicy = iota
+	// CardinalityCollapse renames new metrics to OtherSeries and, in tagged mode,
+	// replaces the source and all tag values of new series with OtherSeries.
+	CardinalityCollapse
+)
+
+// CardinalityLimit caps the number of distinct series a client submits. Zero disables a limit.
+type CardinalityLimit struct {
+	// MaxNames is the maximum number of distinct metric names. With CardinalityCollapse,
+	// OtherSeries is one of them, so MaxNames-1 other names are kept.
+	MaxNames int
+	// MaxSeries is the maximum number of distinct name, source and tag combinations.
+	// It's only enforced in tagged mode, see WithDefaultTags().
+	MaxSeries int
+	Policy    CardinalityPolicy
+}
+
+// CardinalityError is reported once per flush if a cardinality limit was exceeded.
+type CardinalityError struct {
+	// Limit is the limit that was exceeded, either "names" or "series".
+	Limit string
+	Max   int
+	// Count is the number of measurements that were dropped or collapsed
+	// since the last report, and Example is one of their names.
+	Count   int
+	Example string
+}
+
+func (e *CardinalityError) Error() string {
+	return fmt.Sprintf("cardinality limit of %d %s exceeded by %d measurements (e.g. %q)", e.Max, e.Limit, e.Count, e.Example)
+}
+
+// cardinalityGuard tracks the series seen so far. They are never forgotten,
+// even if metrics are removed.
+type cardinalityGuard struct {
+	CardinalityLimit
+
+	mu     sync.Mutex
+	names  map[string]struct{}
+	series map[string]struct{}
+	// Violations since the last report, by limit.
+	exceeded map[string]*CardinalityError
+}
+
+func newCardinalityGuard(limit CardinalityLimit) *cardinalityGuard {
+	return &cardinalityGuard{
+		CardinalityLimit: limit,
+		names:            make(map[string]struct{}),
+		series:           make(map[string]struct{}),
+		exceeded:         make(map[string]*CardinalityError),
+	}
+}
+
+// check enforces the limits on m, collapsing it if needed.
+// It returns false if the measurement should be dropped.
+func (g *cardinalityGuard) check(m *Measurement, tagged bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.MaxNames > 0 {
+		max := g.MaxNames
+		if g.Policy == CardinalityCollapse {
+			// Keep the last slot for OtherSeries.
+			max--
+		}
+		if !admit(g.names, m.Name, max) {
+			g.violate("names", g.MaxNames, m.Name)
+			if g.Policy == CardinalityDrop {
+				return false
+			}
+			m.Name = OtherSeries
+			g.names[OtherSeries] = struct{}{}
+		}
+	}
+	if tagged && g.MaxSeries > 0 {
+		if !admit(g.series, seriesKey(m), g.MaxSeries) {
+			g.violate("series", g.MaxSeries, m.Name)
+			if g.Policy == CardinalityDrop {
+				return false
+			}
+			if m.Source != "" {
+				m.Source = OtherSeries
+			}
+			tags := make(map[string]string, len(m.Tags))
+			for k := range m.Tags {
+				tags[k] = OtherSeries
+			}
+			m.Tags = tags
+		}
+	}
+	return true
+}
+
+// admit adds key to seen unless it's full, and reports whether the key is in it.
+func admit(seen map[string]struct{}, key string, max int) bool {
+	if _, ok := seen[key]; ok {
+		return true
+	}
+	if len(seen) >= max {
+		return false
+	}
+	seen[key] = struct{}{}
+	return true
+}
+
+func (g *cardinalityGuard) violate(limit string, max int, name string) {
+	if e, ok := g.exceeded[limit]; ok {
+		e.Count++
+		return
+	}
+	g.exceeded[limit] = &CardinalityError{Limit: limit, Max: max, Count: 1, Example: name}
+}
+
+// errors returns and clears the violations since the last call.
+func (g *cardinalityGuard) errors() []error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var errs []error
+	for _, limit := range []string{"names", "series"} {
+		if e, ok := g.exceeded[limit]; ok {
+			errs = append(errs, e)
+			delete(g.exceeded, limit)
+		}
+	}
+	return errs
+}
+
+func seriesKey(m *Measurement) string {
+	var b strings.Builder
+	b.WriteString(m.Name)
+	b.WriteByte(0)
+	b.WriteString(m.Source)
+
+	keys := make([]string, 0, len(m.Tags))
+	for k := range m.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(m.Tags[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package librato
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCardinalityCollapseCountsOther(t *testing.T) {
+	g := newCardinalityGuard(CardinalityLimit{MaxNames: 3, Policy: CardinalityCollapse})
+	names := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		m := Measurement{Name: fmt.Sprintf("metric.%d", i)}
+		if !g.check(&m, false) {
+			t.Fatalf("%s was dropped", m.Name)
+		}
+		names[m.Name] = true
+	}
+	if len(names) != 3 || !names[OtherSeries] {
+		t.Errorf("got names %v, want 3 including %q", names, OtherSeries)
+	}
+	if len(g.names) != 3 {
+		t.Errorf("tracking %d names, want 3", len(g.names))
+	}
+}
@@ -0,0 +1,286 @@
+// Package cgroupmetrics collects container resource usage from cgroup v1 or v2 files,
+// so containerized services can report CPU throttling and memory pressure:
+//
+//	stop := client.Collect(cgroupmetrics.New(), time.Minute)
+package cgroupmetrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCgroup is returned by Collect if no cgroup files are found.
+var ErrNoCgroup = errors.New("cgroupmetrics: no cgroup found")
+
+// unlimited is the threshold above which cgroup v1 limits mean "no limit".
+const unlimited = 1 << 62
+
+// Collector implements librato.Collector. Values are reported as gauges named:
+//
+//	<prefix>cpu.usage_percent       CPU time used, in percent of a single core
+//	<prefix>cpu.limit_cores         CPU quota in cores, if there's one
+//	<prefix>cpu.throttled_percent   percent of scheduling periods that were throttled
+//	<prefix>cpu.throttled_seconds   seconds spent throttled per second
+//	<prefix>memory.usage            bytes
+//	<prefix>memory.limit            bytes, if there's a limit
+//	<prefix>memory.usage_percent    usage in percent of the limit, if there's one
+//
+// CPU usage and throttling are rates, so they're only reported from the second call.
+type Collector struct {
+	// Prefix is prepended to metric names. Defaults to "container.".
+	Prefix string
+	// Root is where the cgroup filesystem is mounted. Defaults to /sys/fs/cgroup.
+	Root string
+	// CgroupFile lists the cgroups of the process, which are read under Root.
+	// Defaults to /proc/self/cgroup. If it can't be read, or a cgroup isn't found
+	// under Root (e.g. in a container without a cgroup namespace), Root is read.
+	CgroupFile string
+
+	mu   sync.Mutex
+	prev *sample
+}
+
+// sample holds cumulative counters from the previous call, to compute rates.
+type sample struct {
+	at                 time.Time
+	usage              time.Duration
+	periods, throttled uint64
+	throttledTime      time.Duration
+}
+
+// New returns a Collector with the default settings.
+func New() *Collector {
+	return &Collector{}
+}
+
+// Collect reads the stats of the cgroup the process runs in.
+func (c *Collector) Collect() (map[string]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	root := c.Root
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	file := c.CgroupFile
+	if file == "" {
+		file = "/proc/self/cgroup"
+	}
+	p := c.Prefix
+	if p == "" {
+		p = "container."
+	}
+
+	paths := readCgroupPaths(file)
+	var st *stats
+	var err error
+	if _, statErr := os.Stat(filepath.Join(root, "cgroup.controllers")); statErr == nil {
+		st, err = readV2(cgroupDir(root, "", paths[""]))
+	} else if _, statErr := os.Stat(filepath.Join(root, "cpu", "cpu.stat")); statErr == nil {
+		st, err = readV1(
+			cgroupDir(root, "cpu", paths["cpu"]),
+			cgroupDir(root, "cpuacct", paths["cpuacct"]),
+			cgroupDir(root, "memory", paths["memory"]),
+		)
+	} else {
+		return nil, ErrNoCgroup
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64)
+	values[p+"memory.usage"] = float64(st.memoryUsage)
+	if st.memoryLimit > 0 {
+		values[p+"memory.limit"] = float64(st.memoryLimit)
+		values[p+"memory.usage_percent"] = 100 * float64(st.memoryUsage) / float64(st.memoryLimit)
+	}
+	if st.cpuLimit > 0 {
+		values[p+"cpu.limit_cores"] = st.cpuLimit
+	}
+
+	cur := &sample{time.Now(), st.usage, st.periods, st.throttled, st.throttledTime}
+	if prev := c.prev; prev != nil {
+		wall := cur.at.Sub(prev.at)
+		if wall > 0 && cur.usage >= prev.usage {
+			values[p+"cpu.usage_percent"] = 100 * float64(cur.usage-prev.usage) / float64(wall)
+		}
+		if wall > 0 && cur.throttledTime >= prev.throttledTime {
+			values[p+"cpu.throttled_seconds"] = float64(cur.throttledTime-prev.throttledTime) / float64(wall)
+		}
+		if cur.periods > prev.periods && cur.throttled >= prev.throttled {
+			values[p+"cpu.throttled_percent"] = 100 * float64(cur.throttled-prev.throttled) / float64(cur.periods-prev.periods)
+		}
+	}
+	c.prev = cur
+	return values, nil
+}
+
+// stats are the raw values read from cgroup files. Limits are 0 if there's none.
+type stats struct {
+	usage                    time.Duration
+	periods, throttled       uint64
+	throttledTime            time.Duration
+	cpuLimit                 float64
+	memoryUsage, memoryLimit uint64
+}
+
+// readCgroupPaths reads the cgroups of the process from file, in the format of
+// /proc/self/cgroup. Cgroup v1 paths are keyed by controller, the v2 path by "".
+// It returns nil if the file can't be read.
+func readCgroupPaths(file string) map[string]string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	paths := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		// Lines are "<id>:<controllers>:<path>", e.g. "4:cpu,cpuacct:/docker/abc".
+		// The v2 hierarchy has the ID 0 and no controllers.
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// cgroupDir returns the directory of the cgroup at path, under the hierarchy mounted
+// at root/sub. It falls back to root/sub if there's no such directory.
+func cgroupDir(root, sub, path string) string {
+	dir := filepath.Join(root, sub)
+	if path == "" || path == "/" {
+		return dir
+	}
+	if fi, err := os.Stat(filepath.Join(dir, path)); err == nil && fi.IsDir() {
+		return filepath.Join(dir, path)
+	}
+	return dir
+}
+
+// readV2 reads the stats of the cgroup in dir.
+func readV2(dir string) (*stats, error) {
+	cpu, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	st := &stats{
+		usage:         time.Duration(cpu["usage_usec"]) * time.Microsecond,
+		periods:       cpu["nr_periods"],
+		throttled:     cpu["nr_throttled"],
+		throttledTime: time.Duration(cpu["throttled_usec"]) * time.Microsecond,
+	}
+
+	// cpu.max is "<quota> <period>", where the quota may be "max".
+	if b, err := os.ReadFile(filepath.Join(dir, "cpu.max")); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseFloat(fields[0], 64)
+			period, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 == nil && err2 == nil && period > 0 {
+				st.cpuLimit = quota / period
+			}
+		}
+	}
+
+	if st.memoryUsage, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return nil, err
+	}
+	if limit, err := readUint(filepath.Join(dir, "memory.max")); err == nil {
+		st.memoryLimit = limit
+	}
+	return st, nil
+}
+
+// readV1 reads the stats of the cgroups in the directories of the cpu, cpuacct and
+// memory hierarchies.
+func readV1(cpuDir, cpuacctDir, memoryDir string) (*stats, error) {
+	cpu, err := readKeyValues(filepath.Join(cpuDir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	st := &stats{
+		periods:       cpu["nr_periods"],
+		throttled:     cpu["nr_throttled"],
+		throttledTime: time.Duration(cpu["throttled_time"]),
+	}
+
+	usage, err := readUint(filepath.Join(cpuacctDir, "cpuacct.usage"))
+	if err != nil {
+		return nil, err
+	}
+	st.usage = time.Duration(usage)
+
+	quota, err1 := readInt(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	period, err2 := readInt(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+		st.cpuLimit = float64(quota) / float64(period)
+	}
+
+	if st.memoryUsage, err = readUint(filepath.Join(memoryDir, "memory.usage_in_bytes")); err != nil {
+		return nil, err
+	}
+	if limit, err := readUint(filepath.Join(memoryDir, "memory.limit_in_bytes")); err == nil && limit < unlimited {
+		st.memoryLimit = limit
+	}
+	return st, nil
+}
+
+// readUint reads a file with a single unsigned integer. "max" is returned as an error.
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// readInt reads a file with a single integer, which may be negative (e.g. -1 for no quota).
+func readInt(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readKeyValues reads a file of "key value" lines, like cpu.stat.
+func readKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil {
+			values[k] = n
+		}
+	}
+	return values, s.Err()
+}
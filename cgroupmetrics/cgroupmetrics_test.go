@@ -0,0 +1,103 @@
+package cgroupmetrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates files under dir, by path relative to it.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectOwnCgroup(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		cgroup string
+		files  map[string]string
+	}{
+		{
+			name:   "v2",
+			cgroup: "0::/system.slice/app.service\n",
+			files: map[string]string{
+				"cgroup.controllers":                      "cpu memory",
+				"cpu.stat":                                "usage_usec 1\n",
+				"memory.current":                          "999\n",
+				"system.slice/app.service/cpu.stat":       "usage_usec 1\n",
+				"system.slice/app.service/cpu.max":        "50000 100000\n",
+				"system.slice/app.service/memory.current": "100\n",
+				"system.slice/app.service/memory.max":     "400\n",
+			},
+		},
+		{
+			name:   "v1",
+			cgroup: "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n",
+			files: map[string]string{
+				"cpu/cpu.stat":                            "nr_periods 0\n",
+				"cpuacct/cpuacct.usage":                   "0\n",
+				"memory/memory.usage_in_bytes":            "999\n",
+				"cpu/docker/abc/cpu.stat":                 "nr_periods 0\n",
+				"cpu/docker/abc/cpu.cfs_quota_us":         "50000\n",
+				"cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"cpuacct/docker/abc/cpuacct.usage":        "0\n",
+				"memory/docker/abc/memory.usage_in_bytes": "100\n",
+				"memory/docker/abc/memory.limit_in_bytes": "400\n",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "cgroup")
+			writeFiles(t, root, tc.files)
+			writeFiles(t, dir, map[string]string{"self": tc.cgroup})
+
+			c := &Collector{Root: root, CgroupFile: filepath.Join(dir, "self")}
+			values, err := c.Collect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]float64{
+				"container.memory.usage":         100,
+				"container.memory.limit":         400,
+				"container.memory.usage_percent": 25,
+				"container.cpu.limit_cores":      0.5,
+			}
+			for name, v := range want {
+				if values[name] != v {
+					t.Errorf("%s = %v, want %v", name, values[name], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectWithoutCgroupNamespace(t *testing.T) {
+	// The cgroup of the process isn't visible, since the container only has its own
+	// cgroup mounted at the root.
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"self":                      "0::/kubepods/pod1/ctr\n",
+		"cgroup/cgroup.controllers": "cpu memory",
+		"cgroup/cpu.stat":           "usage_usec 1\n",
+		"cgroup/memory.current":     "100\n",
+	})
+
+	c := &Collector{Root: filepath.Join(dir, "cgroup"), CgroupFile: filepath.Join(dir, "self")}
+	values, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["container.memory.usage"] != 100 {
+		t.Errorf("memory.usage = %v, want 100", values["container.memory.usage"])
+	}
+}
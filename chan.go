@@ -1,5 +1,11 @@
 package librato
 
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
 // Chan represents a channel.
 type Chan interface {
 	// Input returns a channel that can be written to.
@@ -12,52 +18,262 @@ type Chan interface {
 	Wait()
 }
 
-// FlexibleChan is a dynamically resizing channel.
+// BufferedChan is implemented by channels that can report their buffer, like
+// FlexibleChan and FlexibleMPSCChan. Chans don't have to, check with a type assertion:
+//
+//	if b, ok := client.GetGauge("latency").(librato.BufferedChan); ok {
+//		log.Printf("%d of %d buffered", b.Len(), b.Cap())
+//	}
+type BufferedChan interface {
+	// Len returns the number of buffered items.
+	Len() int
+	// Cap returns the current buffer capacity. It changes as the channel resizes.
+	Cap() int
+}
+
+// TryPushChan is implemented by channels that can be written to without blocking, like
+// FlexibleChan and FlexibleMPSCChan. See BufferedChan.
+type TryPushChan interface {
+	// TryPush writes an item without blocking. It returns false if the
+	// channel can't accept it right away, in which case the item is dropped.
+	TryPush(item interface{}) bool
+}
+
+// ContextChan is implemented by channels whose pushes and pops can be cancelled, like
+// FlexibleChan and FlexibleMPSCChan. See BufferedChan.
+type ContextChan interface {
+	// PushContext writes an item, blocking until it's accepted or ctx is done.
+	PushContext(ctx context.Context, item interface{}) error
+	// PopContext reads an item, blocking until one is available or ctx is done.
+	// ok is false if the channel is closed and drained.
+	PopContext(ctx context.Context) (item interface{}, ok bool, err error)
+}
+
+var (
+	_ BufferedChan = (*FlexibleChan)(nil)
+	_ BufferedChan = (*FlexibleMPSCChan)(nil)
+	_ TryPushChan  = (*FlexibleChan)(nil)
+	_ TryPushChan  = (*FlexibleMPSCChan)(nil)
+	_ ContextChan  = (*FlexibleChan)(nil)
+	_ ContextChan  = (*FlexibleMPSCChan)(nil)
+)
+
+// FlexibleChan is a TypedChan of arbitrary items, implementing Chan.
+type FlexibleChan = TypedChan[interface{}]
+
+// TypedChan is a dynamically resizing channel.
 // It has a minimum capacity of "ms".
-type FlexibleChan struct {
-	rx   chan interface{}
-	tx   chan interface{}
+type TypedChan[T any] struct {
+	rx   chan T
+	tx   chan T
 	quit chan struct{}
-	buf  *Queue
+	buf  *TypedQueue[T]
 	ms   int
+
+	// Optional item size estimator and the maximum number of bytes
+	// the worker can hold before it stops accepting new items.
+	sizer    func(T) int
+	maxBytes int
+
+	// Number of items (and their size in bytes) held by the worker, buffered
+	// or waiting to be sent, and the capacity of its buffer, for Len(), Size() and Cap().
+	held      atomic.Int64
+	heldBytes atomic.Int64
+	bufCap    atomic.Int64
+
+	// tees receive a copy of every item, see Tee(). They're read by the worker
+	// without locking, and replaced under teeMu.
+	tees       atomic.Pointer[[]*TypedChan[T]]
+	teeMu      sync.Mutex
+	teesClosed bool
 }
 
 func NewFlexibleChan(ms int) *FlexibleChan {
-	ch := &FlexibleChan{
-		rx:   make(chan interface{}, ms),
-		tx:   make(chan interface{}, ms),
-		quit: make(chan struct{}),
-		buf:  NewQueue(2 << 10),
-		ms:   ms,
+	return NewTypedChan[interface{}](ms)
+}
+
+func NewTypedChan[T any](ms int) *TypedChan[T] {
+	return NewSizedChan[T](ms, nil, 0)
+}
+
+// NewSizedChan returns a TypedChan that tracks the size of its internal buffer in bytes
+// using sizer. Once the buffer holds maxBytes or more, the channel stops accepting new items
+// until it drains, so Input() and Push() block and TryPush() fails. A maxBytes of 0
+// only tracks the size, see Size().
+func NewSizedChan[T any](ms int, sizer func(T) int, maxBytes int) *TypedChan[T] {
+	ch := &TypedChan[T]{
+		rx:       make(chan T, ms),
+		tx:       make(chan T, ms),
+		quit:     make(chan struct{}),
+		buf:      NewTypedQueue[T](2 << 10),
+		ms:       ms,
+		sizer:    sizer,
+		maxBytes: maxBytes,
 	}
+	ch.buf.SetSizer(sizer)
+	ch.bufCap.Store(int64(ch.buf.Cap()))
 	go ch.work()
 	return ch
 }
 
-func (c *FlexibleChan) Close() {
+func (c *TypedChan[T]) Close() {
 	close(c.rx)
 }
 
-func (c *FlexibleChan) Wait() {
+func (c *TypedChan[T]) Wait() {
 	<-c.quit
 }
 
-func (c *FlexibleChan) Input() chan<- interface{} {
+func (c *TypedChan[T]) Input() chan<- T {
 	return c.rx
 }
 
-func (c *FlexibleChan) Output() <-chan interface{} {
+func (c *TypedChan[T]) Output() <-chan T {
 	return c.tx
 }
 
-func (c *FlexibleChan) work() {
-	var inCh, outCh chan interface{} = c.rx, nil
-	var inItem, outItem interface{}
+// Len returns the number of buffered items, including the internal queue.
+// Since the channel is in constant use, the result is only a snapshot.
+func (c *TypedChan[T]) Len() int {
+	return len(c.rx) + len(c.tx) + int(c.held.Load())
+}
+
+// Size returns the estimated size in bytes of the items held in the internal queue.
+// It's always 0 for channels without a sizer, see NewSizedChan.
+func (c *TypedChan[T]) Size() int {
+	return int(c.heldBytes.Load())
+}
+
+// Cap returns the current buffer capacity, including the internal queue.
+func (c *TypedChan[T]) Cap() int {
+	return cap(c.rx) + cap(c.tx) + int(c.bufCap.Load())
+}
+
+// Push writes an item to the channel. Like Input(), it must not be called after Close().
+func (c *TypedChan[T]) Push(item T) {
+	c.rx <- item
+}
+
+// TryPush writes an item if the channel can accept it without blocking
+// and reports whether it did. The intake only blocks momentarily while the
+// channel resizes, so this is useful for latency critical paths that would
+// rather drop an item than stall. It must not be called after Close().
+func (c *TypedChan[T]) TryPush(item T) bool {
+	select {
+	case c.rx <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pop reads an item from the channel, blocking until one is available.
+// The second return value is false if the channel is closed and drained.
+// Like reading from Output(), it's safe to call from several goroutines,
+// each item is then read by only one of them.
+func (c *TypedChan[T]) Pop() (T, bool) {
+	item, ok := <-c.tx
+	return item, ok
+}
+
+// PushContext writes an item, blocking until the channel accepts it or ctx is done,
+// in which case the item is dropped and ctx.Err() is returned.
+// It must not be called after Close().
+func (c *TypedChan[T]) PushContext(ctx context.Context, item T) error {
+	select {
+	case c.rx <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PopContext reads an item, blocking until one is available or ctx is done.
+// ok is false if the channel is closed and drained, err is ctx.Err() if ctx is done first.
+func (c *TypedChan[T]) PopContext(ctx context.Context) (item T, ok bool, err error) {
+	select {
+	case item, ok = <-c.tx:
+		return item, ok, nil
+	case <-ctx.Done():
+		return item, false, ctx.Err()
+	}
+}
+
+// Tee returns a channel that receives a copy of every item written to c from now on,
+// in addition to c's own consumer, e.g. to log the values pushed to a metric while they
+// are still collated:
+//
+//	if ch, ok := client.GetGauge("latency").(*librato.FlexibleChan); ok {
+//		logged := ch.Tee()
+//		// ...
+//	}
+//
+// Tees are per channel, there's no client-wide tee of every metric. The metric channels
+// are FlexibleMPSCChans with WithMPSCChannels(), see MPSCChan.Tee(), and values pushed
+// with PushGauge() or PushCounter() skip them in dispatcher mode.
+//
+// The tee has the same minimum capacity and size limit as c, and is closed once c
+// is closed and drained. With a size limit, a tee that isn't read eventually blocks c.
+func (c *TypedChan[T]) Tee() *TypedChan[T] {
+	t := NewSizedChan[T](c.ms, c.sizer, c.maxBytes)
+
+	c.teeMu.Lock()
+	defer c.teeMu.Unlock()
+	if c.teesClosed {
+		t.Close()
+		return t
+	}
+	var tees []*TypedChan[T]
+	if old := c.tees.Load(); old != nil {
+		tees = append(tees, *old...)
+	}
+	tees = append(tees, t)
+	c.tees.Store(&tees)
+	return t
+}
+
+// finish closes the output channel and the tees once the worker is done.
+func (c *TypedChan[T]) finish() {
+	close(c.tx)
+
+	c.teeMu.Lock()
+	c.teesClosed = true
+	if tees := c.tees.Load(); tees != nil {
+		for _, t := range *tees {
+			t.Close()
+		}
+	}
+	c.teeMu.Unlock()
+
+	close(c.quit)
+}
+
+func (c *TypedChan[T]) work() {
+	var inCh, outCh chan T = c.rx, nil
+	var inItem, outItem T
 	var ok bool
 
 	for {
+		held, heldBytes := c.buf.Length(), c.buf.Size()
+		if outCh != nil {
+			held++
+			if c.sizer != nil {
+				heldBytes += c.sizer(outItem)
+			}
+		}
+		c.held.Store(int64(held))
+		c.heldBytes.Store(int64(heldBytes))
+		c.bufCap.Store(int64(c.buf.Cap()))
+
+		// Stop receiving while over the size limit. The buffer can't be empty
+		// in that case, so the output case below is still enabled.
+		recvCh := inCh
+		if c.maxBytes > 0 && heldBytes >= c.maxBytes {
+			recvCh = nil
+		}
+
 		select {
-		case inItem, ok = <-inCh:
+		case inItem, ok = <-recvCh:
 			if !ok {
 				// Input channel is closed, so we need to finish up and stop the worker.
 				// If outCh is nil, it means the buffer is empty and we can go ahead
@@ -65,13 +281,17 @@ func (c *FlexibleChan) work() {
 				// worker (this select case) and let the output worker (the other select
 				// case) continue until the buffer is cleared.
 				if outCh == nil {
-					close(c.tx)
-					close(c.quit)
+					c.finish()
 					return
 				}
 				inCh = nil
 				break
 			}
+			if tees := c.tees.Load(); tees != nil {
+				for _, t := range *tees {
+					t.Push(inItem)
+				}
+			}
 
 			// If output channel is disabled, re-enable it and send the input item.
 			if outCh == nil {
@@ -89,8 +309,7 @@ func (c *FlexibleChan) work() {
 				if inCh == nil {
 					// The buffer is empty *and* the input channel is closed, which means we are stopping
 					// the worker. Simply close the output (c.tx) and return.
-					close(c.tx)
-					close(c.quit)
+					c.finish()
 					return
 				}
 				// The buffer is empty, so disable outCh, which will be re-enabled on the input side.
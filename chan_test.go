@@ -0,0 +1,83 @@
+package librato
+
+import "testing"
+
+// benchmarkChan pushes from GOMAXPROCS goroutines while a single reader drains the
+// channel, the way metric goroutines drain the channels of GetGauge() and GetCounter().
+// Channels have the buffer size of newMetricChan().
+func benchmarkChan(b *testing.B, ch Chan) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range ch.Output() {
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ch.Input() <- 1.5
+		}
+	})
+	ch.Close()
+	<-done
+}
+
+func BenchmarkFlexibleChan(b *testing.B) {
+	benchmarkChan(b, NewFlexibleChan(2<<9))
+}
+
+func BenchmarkMPSCChan(b *testing.B) {
+	b.Run("Input", func(b *testing.B) {
+		benchmarkChan(b, NewFlexibleMPSCChan(2<<9))
+	})
+	b.Run("Push", func(b *testing.B) {
+		ch := NewFlexibleMPSCChan(2 << 9)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, ok := ch.Pop(); !ok {
+					return
+				}
+			}
+		}()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				ch.Push(1.5)
+			}
+		})
+		ch.Close()
+		<-done
+	})
+}
+
+func TestMPSCChanTee(t *testing.T) {
+	ch := NewMPSCChan[int](2)
+	ch.Push(1)
+	tee := ch.Tee()
+	ch.Push(2)
+	ch.Push(3)
+	ch.Close()
+
+	var got []int
+	for {
+		item, ok := tee.Pop()
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("tee got %v, want [2 3]", got)
+	}
+	if late := ch.Tee(); late.Len() != 0 {
+		t.Errorf("tee of a closed channel has %d items", late.Len())
+	} else if _, ok := late.Pop(); ok {
+		t.Error("tee of a closed channel isn't closed")
+	}
+}
@@ -0,0 +1,97 @@
+package librato_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestCloseDeliversEverything(t *testing.T) {
+	const producers, pushes = 8, 500
+
+	for _, tc := range []struct {
+		name string
+		opts []librato.Option
+	}{
+		{"channels", nil},
+		{"dispatcher", []librato.Option{librato.WithDispatcher(4)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := libratotest.NewServer()
+			defer srv.Close()
+			c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour, tc.opts...)
+			c.SetEndpoint(srv.Endpoint())
+
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < pushes; i++ {
+						// A new metric every few pushes, so metrics are registered concurrently with Close.
+						c.PushGauge(fmt.Sprintf("gauge.%d.%d", p, i/50), i)
+						c.PushCounter(fmt.Sprintf("counter.%d", p), i)
+					}
+				}(p)
+			}
+			wg.Wait()
+			c.Close()
+			c.Wait()
+
+			var gauges, counters int
+			for _, b := range srv.Batches() {
+				gauges += len(b.Gauges)
+				counters += len(b.Counters)
+			}
+			if want := producers * pushes; gauges != want || counters != want {
+				t.Errorf("got %d gauges and %d counters, want %d of each", gauges, counters, want)
+			}
+		})
+	}
+}
+
+func TestGetGaugeAfterClose(t *testing.T) {
+	c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour)
+	c.Close()
+	c.Wait()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		c.GetGauge(fmt.Sprintf("gauge.%d", i)).Input() <- i
+		c.PushGauge(fmt.Sprintf("gauge.%d", i), i)
+	}
+	if after := runtime.NumGoroutine(); after > before+1 {
+		t.Errorf("%d goroutines before, %d after retrieving gauges after Close", before, after)
+	}
+}
+
+func TestPushExistingGaugeAfterClose(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []librato.Option
+	}{
+		{"channels", nil},
+		{"mpsc", []librato.Option{librato.WithMPSCChannels()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := libratotest.NewServer()
+			defer srv.Close()
+			c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour, tc.opts...)
+			c.SetEndpoint(srv.Endpoint())
+			c.PushGauge("gauge", 1)
+			c.PushCounter("counter", 1)
+			c.Close()
+			c.Wait()
+
+			// The metrics' channels are closed, so these must be dropped rather than panic.
+			c.PushGauge("gauge", 2)
+			c.PushCounter("counter", 2)
+			c.GetGauge("gauge").Input() <- 3
+		})
+	}
+}
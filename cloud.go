@@ -0,0 +1,155 @@
+package librato
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Cloud metadata services, see DetectCloudMetadata().
+const (
+	ec2MetadataURL = "http://169.254.169.254/latest"
+	gceMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+	cloudDetectTimeout = time.Second
+)
+
+// ErrNoCloudMetadata is returned by DetectCloudMetadata when no known
+// metadata service is reachable.
+var ErrNoCloudMetadata = errors.New("no cloud metadata service found")
+
+// CloudMetadata describes the cloud instance the process runs on.
+type CloudMetadata struct {
+	// Provider is either "ec2" or "gce".
+	Provider     string
+	InstanceID   string
+	Zone         string
+	InstanceType string
+}
+
+// Tags returns the metadata as tags, for use with tagged measurements.
+func (m *CloudMetadata) Tags() map[string]string {
+	return map[string]string{
+		"cloud":             m.Provider,
+		"instance_id":       m.InstanceID,
+		"availability_zone": m.Zone,
+		"instance_type":     m.InstanceType,
+	}
+}
+
+// DetectCloudMetadata queries the EC2 and GCE metadata services and returns the
+// metadata of the first one that responds, or ErrNoCloudMetadata.
+func DetectCloudMetadata(ctx context.Context) (*CloudMetadata, error) {
+	client := &http.Client{}
+	if m, err := detectEC2(ctx, client); err == nil {
+		return m, nil
+	}
+	if m, err := detectGCE(ctx, client); err == nil {
+		return m, nil
+	}
+	return nil, ErrNoCloudMetadata
+}
+
+func detectEC2(ctx context.Context, client *http.Client) (*CloudMetadata, error) {
+	// IMDSv2 requires a session token.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, ec2MetadataURL+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := metadataRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(p string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ec2MetadataURL+"/meta-data/"+p, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+		return metadataRequest(client, req)
+	}
+
+	m := &CloudMetadata{Provider: "ec2"}
+	if m.InstanceID, err = get("instance-id"); err != nil {
+		return nil, err
+	}
+	if m.Zone, err = get("placement/availability-zone"); err != nil {
+		return nil, err
+	}
+	if m.InstanceType, err = get("instance-type"); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func detectGCE(ctx context.Context, client *http.Client) (*CloudMetadata, error) {
+	get := func(p string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, gceMetadataURL+"/instance/"+p, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return metadataRequest(client, req)
+	}
+
+	var err error
+	m := &CloudMetadata{Provider: "gce"}
+	if m.InstanceID, err = get("id"); err != nil {
+		return nil, err
+	}
+	// Zones and machine types are returned as paths, e.g. "projects/123/zones/us-central1-a".
+	if m.Zone, err = get("zone"); err != nil {
+		return nil, err
+	}
+	m.Zone = path.Base(m.Zone)
+	if m.InstanceType, err = get("machine-type"); err != nil {
+		return nil, err
+	}
+	m.InstanceType = path.Base(m.InstanceType)
+	return m, nil
+}
+
+func metadataRequest(client *http.Client, req *http.Request) (string, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata service responded with %d", res.StatusCode)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// applyCloudMetadata detects the cloud environment, using the instance ID as the
+// source if there's none, and adding the metadata to default tags in tagged mode.
+func (c *TimeCollatedClient) applyCloudMetadata(source string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), cloudDetectTimeout)
+	defer cancel()
+
+	m, err := DetectCloudMetadata(ctx)
+	if err != nil {
+		c.reportError(fmt.Errorf("cloud metadata: %w", err))
+		return source
+	}
+
+	if c.tagged {
+		c.mergeDefaultTags(m.Tags())
+	}
+	if source == "" {
+		source = sanitize(m.InstanceID)
+	}
+	return source
+}
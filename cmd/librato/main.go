@@ -0,0 +1,241 @@
+// Command librato pushes measurements and annotations to Librato from the command line,
+// e.g. from shell scripts and cron jobs:
+//
+//	librato gauge app.latency 42 --source web1
+//	librato counter jobs.processed 10
+//	librato gauge temperature -5 --source outside
+//	librato annotate deploys "v1.2.3" --description "Deployed by CI"
+//
+// Credentials are read from LIBRATO_USER and LIBRATO_TOKEN. LIBRATO_SOURCE sets the
+// default source, LIBRATO_TAGS switches to tagged measurements (see ParseTags) and
+// LIBRATO_ENDPOINT overrides the API endpoint.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dcelasun/librato"
+)
+
+const usage = `Usage:
+  librato gauge NAME VALUE [flags]
+  librato counter NAME VALUE [flags]
+  librato annotate STREAM TITLE [flags]
+
+Run "librato COMMAND -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "gauge", "counter":
+		err = measure(cmd, args)
+	case "annotate":
+		err = annotate(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "librato: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "librato: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func measure(kind string, args []string) error {
+	fs := flag.NewFlagSet(kind, flag.ExitOnError)
+	source := fs.String("source", "", "source of the measurement, defaults to LIBRATO_SOURCE or the hostname")
+	at := fs.String("time", "", "time of the measurement, in Unix seconds or RFC 3339, defaults to now")
+	tags := fs.String("tags", "", "tags of the measurement, e.g. \"env=prod,region=us-east-1\"")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the request")
+	pos := parse(fs, args)
+	if len(pos) != 2 {
+		return fmt.Errorf("%s takes a NAME and a VALUE, got %d arguments", kind, len(pos))
+	}
+
+	m := librato.Measurement{Name: pos[0], Source: *source}
+	if kind == "counter" {
+		v, err := strconv.ParseInt(pos[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid counter value %q: %w", pos[1], err)
+		}
+		m.Value = v
+	} else {
+		v, err := strconv.ParseFloat(pos[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid gauge value %q: %w", pos[1], err)
+		}
+		m.Value = v
+	}
+	if *at != "" {
+		t, err := parseTime(*at)
+		if err != nil {
+			return err
+		}
+		m.SetTime(t)
+	}
+	if *tags != "" {
+		t, err := librato.ParseTags(*tags)
+		if err != nil {
+			return err
+		}
+		m.Tags = t
+	}
+
+	c, err := newClient(m.Tags != nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if kind == "counter" {
+		return c.SubmitCounters(ctx, []librato.Measurement{m})
+	}
+	return c.SubmitGauges(ctx, []librato.Measurement{m})
+}
+
+func annotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	source := fs.String("source", "", "source of the annotation")
+	description := fs.String("description", "", "description of the annotation")
+	link := fs.String("link", "", "URL to link from the annotation")
+	start := fs.String("start", "", "start time, in Unix seconds or RFC 3339, defaults to now")
+	end := fs.String("end", "", "end time, in Unix seconds or RFC 3339")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the request")
+	pos := parse(fs, args)
+	if len(pos) != 2 {
+		return fmt.Errorf("annotate takes a STREAM and a TITLE, got %d arguments", len(pos))
+	}
+
+	b := librato.NewAnnotationBuilder(pos[1])
+	if *source != "" {
+		b.Source(*source)
+	}
+	if *description != "" {
+		b.Description(*description)
+	}
+	if *link != "" {
+		b.Link("link", *link, "")
+	}
+	if *start != "" {
+		t, err := parseTime(*start)
+		if err != nil {
+			return err
+		}
+		b.StartTime(t)
+	}
+	if *end != "" {
+		t, err := parseTime(*end)
+		if err != nil {
+			return err
+		}
+		b.EndTime(t)
+	}
+	a, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(false)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return c.PostAnnotation(ctx, a, pos[0])
+}
+
+// parse parses flags that may come before, between or after positional
+// arguments, which the flag package stops at. It returns the positional ones.
+// Numbers such as -5 are positional, as is everything after "--".
+func parse(fs *flag.FlagSet, args []string) []string {
+	var flags, pos []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			pos = append(pos, args[i+1:]...)
+			break
+		}
+		if len(a) < 2 || a[0] != '-' || isNumber(a) {
+			pos = append(pos, a)
+			continue
+		}
+		flags = append(flags, a)
+		name := strings.TrimPrefix(a[1:], "-")
+		if strings.Contains(name, "=") || isBoolFlag(fs.Lookup(name)) || i+1 == len(args) {
+			continue
+		}
+		// The flag takes a value, which may itself look like a flag or a number.
+		i++
+		flags = append(flags, args[i])
+	}
+	fs.Parse(flags)
+	return pos
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// isBoolFlag reports whether f is a boolean flag, which takes no separate value.
+// Unknown flags are left to the flag package to report.
+func isBoolFlag(f *flag.Flag) bool {
+	if f == nil {
+		return true
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func parseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected Unix seconds or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// newClient creates a client from the environment. Tags are only sent to the tagged
+// measurements API, so it's used if tagged is set, even without LIBRATO_TAGS.
+func newClient(tagged bool) (*librato.SimpleClient, error) {
+	user, token := os.Getenv("LIBRATO_USER"), os.Getenv("LIBRATO_TOKEN")
+	if user == "" || token == "" {
+		return nil, errors.New("LIBRATO_USER and LIBRATO_TOKEN must be set")
+	}
+
+	var opts []librato.Option
+	if v := os.Getenv("LIBRATO_TAGS"); v != "" {
+		tags, err := librato.ParseTags(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LIBRATO_TAGS: %w", err)
+		}
+		opts = append(opts, librato.WithDefaultTags(tags))
+	} else if tagged {
+		opts = append(opts, librato.WithDefaultTags(nil))
+	}
+
+	c := librato.NewSimpleClient(user, token, os.Getenv("LIBRATO_SOURCE"), opts...)
+	if v := os.Getenv("LIBRATO_ENDPOINT"); v != "" {
+		c.SetEndpoint(v)
+	}
+	return c, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args   []string
+		pos    []string
+		source string
+		quiet  bool
+	}{
+		{[]string{"x", "42"}, []string{"x", "42"}, "", false},
+		{[]string{"x", "-5"}, []string{"x", "-5"}, "", false},
+		{[]string{"x", "-1.5e3", "--source", "web"}, []string{"x", "-1.5e3"}, "web", false},
+		{[]string{"-source", "web", "x", "-5"}, []string{"x", "-5"}, "web", false},
+		{[]string{"x", "-source=web", "-5", "-quiet"}, []string{"x", "-5"}, "web", true},
+		{[]string{"-quiet", "x", "-source", "-web-", "1"}, []string{"x", "1"}, "-web-", true},
+		{[]string{"-source", "web", "--", "-x", "-source"}, []string{"-x", "-source"}, "web", false},
+		{[]string{"x", "-"}, []string{"x", "-"}, "", false},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		source := fs.String("source", "", "")
+		quiet := fs.Bool("quiet", false, "")
+		pos := parse(fs, tt.args)
+		if !reflect.DeepEqual(pos, tt.pos) || *source != tt.source || *quiet != tt.quiet {
+			t.Errorf("parse(%q) = %q, source %q, quiet %v; want %q, %q, %v",
+				tt.args, pos, *source, *quiet, tt.pos, tt.source, tt.quiet)
+		}
+	}
+}
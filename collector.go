@@ -0,0 +1,130 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Collector samples values to be reported as gauges, e.g. host or runtime stats.
+// See Collect().
+type Collector interface {
+	// Collect returns gauge values by metric name. Values returned along with an
+	// error are still reported.
+	Collect() (map[string]float64, error)
+}
+
+// CollectorFunc is a function implementing Collector.
+type CollectorFunc func() (map[string]float64, error)
+
+func (f CollectorFunc) Collect() (map[string]float64, error) {
+	return f()
+}
+
+// Collect calls col every interval and pushes the values it returns as gauges, until
+// stop is called or the client is closed. Errors are passed to the error handler.
+func (c *TimeCollatedClient) Collect(col Collector, interval time.Duration) (stop func()) {
+	return c.every(interval, func() {
+		values, err := col.Collect()
+		if err != nil {
+			c.reportError(fmt.Errorf("collector: %w", err))
+		}
+		for name, v := range values {
+			c.PushGauge(name, v)
+		}
+	})
+}
+
+// goPoller runs fn in a goroutine that Close() waits for, unless the client is closing.
+// It reports whether it did.
+func (c *TimeCollatedClient) goPoller(fn func()) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.goPollerLocked(fn)
+}
+
+// goPollerLocked is goPoller() with c.mu held. Close() closes c.closing under c.mu, so
+// pollers aren't added while it waits for them.
+func (c *TimeCollatedClient) goPollerLocked(fn func()) bool {
+	select {
+	case <-c.closing:
+		return false
+	default:
+	}
+	c.pollers.Add(1)
+	go func() {
+		defer c.pollers.Done()
+		fn()
+	}()
+	return true
+}
+
+// closeGrace is how long requests of pollers may still take once Close() is called.
+const closeGrace = 5 * time.Second
+
+// closingContext returns a context for requests made by pollers. It's cancelled
+// closeGrace after Close() is called, so that a slow endpoint can't hold up Close(),
+// which waits for pollers, while their last requests still get a chance to finish.
+func (c *TimeCollatedClient) closingContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-c.closing:
+		case <-ctx.Done():
+			return
+		}
+		t := time.NewTimer(closeGrace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// every calls fn every interval in its own goroutine, until stop is called or the
+// client is closed. A panic in fn is reported and doesn't stop it.
+func (c *TimeCollatedClient) every(interval time.Duration, fn func()) (stop func()) {
+	done := make(chan struct{})
+	c.goPoller(func() {
+		t := c.clock.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-c.closing:
+				return
+			case <-t.C():
+				func() {
+					defer func() {
+						c.recovered(recover())
+					}()
+					fn()
+				}()
+			}
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// ReportEvery calls fn every interval and pushes the value it returns as the named
+// gauge, e.g. to report a queue depth or a cache size. Errors are passed to the error
+// handler instead. It stops when stop is called or the client is closed.
+func (c *TimeCollatedClient) ReportEvery(name string, interval time.Duration, fn func() (float64, error)) (stop func()) {
+	return c.every(interval, func() {
+		v, err := fn()
+		if err != nil {
+			c.reportError(fmt.Errorf("report %s: %w", name, err))
+			return
+		}
+		c.PushGauge(name, v)
+	})
+}
@@ -0,0 +1,80 @@
+package librato
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ComposeQuery evaluates a composite metric expression, e.g.
+// `divide([sum(s("errors", "*")), sum(s("requests", "*"))])`.
+// http://api-docs-archive.librato.com/#composite-metric-queries
+type ComposeQuery struct {
+	Expression string
+	// StartTime is required. A zero EndTime means now.
+	StartTime, EndTime time.Time
+	// Resolution is the period of the returned points, rounded up by Librato to a
+	// supported resolution. A zero Resolution lets Librato pick one.
+	Resolution time.Duration
+}
+
+// ComposeResult is the result of a ComposeQuery.
+type ComposeResult struct {
+	Compose string `json:"compose"`
+	// Resolution of the points in seconds.
+	Resolution int      `json:"resolution"`
+	Series     []Series `json:"measurements"`
+}
+
+// Series is a single series of a ComposeResult.
+type Series struct {
+	Metric struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"metric"`
+	Source struct {
+		Name string `json:"name"`
+	} `json:"source"`
+	Points []Point `json:"series"`
+}
+
+// Point is a value of a series at a given time.
+type Point struct {
+	MeasureTime int64   `json:"measure_time"`
+	Value       float64 `json:"value"`
+}
+
+// Time returns the measure_time of the point.
+func (p Point) Time() time.Time {
+	return time.Unix(p.MeasureTime, 0)
+}
+
+// Compose evaluates a composite metric expression.
+func (c *TimeCollatedClient) Compose(ctx context.Context, q ComposeQuery) (*ComposeResult, error) {
+	if q.Expression == "" {
+		return nil, errors.New("librato: compose expression is required")
+	}
+	if q.StartTime.IsZero() {
+		return nil, errors.New("librato: compose start time is required")
+	}
+
+	query := url.Values{
+		"compose":    {q.Expression},
+		"start_time": {strconv.FormatInt(q.StartTime.Unix(), 10)},
+	}
+	if !q.EndTime.IsZero() {
+		query.Set("end_time", strconv.FormatInt(q.EndTime.Unix(), 10))
+	}
+	if q.Resolution > 0 {
+		query.Set("resolution", strconv.FormatInt(int64(q.Resolution/time.Second), 10))
+	}
+
+	res := &ComposeResult{}
+	if err := c.apiRequest(ctx, http.MethodGet, "/metrics", query, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package librato
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Duration is a time.Duration that's encoded as a string like "10s" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// RetryConfig is the config file equivalent of RetryPolicy.
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	Backoff     Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	MaxBackoff  Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+}
+
+// Config holds client settings, so that they can be loaded from config files.
+// See NewClientFromConfig().
+type Config struct {
+	User   string `json:"user" yaml:"user"`
+	Token  string `json:"token" yaml:"token"`
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Endpoint defaults to the Librato API.
+	Endpoint      string   `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	FlushInterval Duration `json:"flush_interval" yaml:"flush_interval"`
+	// MaxBatchSize defaults to MaxMetrics.
+	MaxBatchSize int         `json:"max_batch_size,omitempty" yaml:"max_batch_size,omitempty"`
+	Retry        RetryConfig `json:"retry" yaml:"retry,omitempty"`
+	// Tags switches the client to tagged measurements, see WithDefaultTags().
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Allow and Deny are metric name patterns, see NewNameFilter().
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// Validate checks that the config is complete and its values are valid.
+func (cfg Config) Validate() error {
+	var errs []error
+	if cfg.User == "" {
+		errs = append(errs, errors.New("user is required"))
+	}
+	if cfg.Token == "" {
+		errs = append(errs, errors.New("token is required"))
+	}
+	if cfg.Endpoint != "" {
+		if u, err := url.Parse(cfg.Endpoint); err != nil || !u.IsAbs() {
+			errs = append(errs, fmt.Errorf("endpoint %q is not an absolute URL", cfg.Endpoint))
+		}
+	}
+	if cfg.FlushInterval <= 0 {
+		errs = append(errs, errors.New("flush_interval must be positive"))
+	}
+	if cfg.MaxBatchSize < 0 {
+		errs = append(errs, errors.New("max_batch_size can't be negative"))
+	}
+	if cfg.Retry.MaxAttempts < 0 {
+		errs = append(errs, errors.New("retry.max_attempts can't be negative"))
+	}
+	if cfg.Retry.Backoff < 0 || cfg.Retry.MaxBackoff < 0 {
+		errs = append(errs, errors.New("retry backoff can't be negative"))
+	}
+	if _, err := NewNameFilter(cfg.Allow, cfg.Deny); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("librato: invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// NewClientFromConfig validates cfg and creates a client with it.
+// Options are applied after the config, so they take precedence.
+func NewClientFromConfig(cfg Config, opts ...Option) (*TimeCollatedClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfgOpts := []Option{
+		WithRetry(RetryPolicy{
+			MaxAttempts: cfg.Retry.MaxAttempts,
+			Backoff:     time.Duration(cfg.Retry.Backoff),
+			MaxBackoff:  time.Duration(cfg.Retry.MaxBackoff),
+		}),
+	}
+	if len(cfg.Tags) > 0 {
+		cfgOpts = append(cfgOpts, WithDefaultTags(cfg.Tags))
+	}
+	if len(cfg.Allow) > 0 || len(cfg.Deny) > 0 {
+		f, _ := NewNameFilter(cfg.Allow, cfg.Deny)
+		cfgOpts = append(cfgOpts, WithNameFilter(f))
+	}
+	c := NewTimeCollatedClient(cfg.User, cfg.Token, cfg.Source, time.Duration(cfg.FlushInterval), append(cfgOpts, opts...)...)
+	if cfg.Endpoint != "" {
+		c.SetEndpoint(cfg.Endpoint)
+	}
+	if cfg.MaxBatchSize > 0 {
+		c.SetMaxBatchSize(cfg.MaxBatchSize)
+	}
+	return c, nil
+}
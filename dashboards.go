@@ -0,0 +1,195 @@
+package librato
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Dashboards declares spaces and their charts, to be kept in sync with an account by
+// SyncDashboards(), so that dashboards can be versioned along with the service. Spaces
+// and charts are identified by their names, which must be unique.
+//
+// It can be loaded from JSON with LoadDashboards(), or from YAML with any library
+// that supports yaml struct tags.
+type Dashboards struct {
+	Spaces []Space `json:"spaces" yaml:"spaces"`
+	// Prune deletes spaces of the account that aren't declared. Otherwise they're left alone.
+	// Charts that aren't declared are always deleted from declared spaces.
+	Prune bool `json:"prune,omitempty" yaml:"prune,omitempty"`
+}
+
+// LoadDashboards decodes dashboard definitions from JSON.
+func LoadDashboards(r io.Reader) (*Dashboards, error) {
+	defs := &Dashboards{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	// Charts aren't encoded with spaces by the API, so decode them separately.
+	var raw struct {
+		Spaces []struct {
+			Name   string  `json:"name"`
+			Charts []Chart `json:"charts"`
+		} `json:"spaces"`
+		Prune bool `json:"prune"`
+	}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("librato: dashboards: %w", err)
+	}
+	defs.Prune = raw.Prune
+	for _, s := range raw.Spaces {
+		defs.Spaces = append(defs.Spaces, Space{Name: s.Name, Charts: s.Charts})
+	}
+	return defs, nil
+}
+
+// DashboardChange is a change made by SyncDashboards().
+type DashboardChange struct {
+	// Action is "create", "update" or "delete".
+	Action string
+	Space  string
+	// Chart is empty for changes to the space itself.
+	Chart string
+}
+
+func (c DashboardChange) String() string {
+	if c.Chart == "" {
+		return fmt.Sprintf("%s space %q", c.Action, c.Space)
+	}
+	return fmt.Sprintf("%s chart %q in space %q", c.Action, c.Chart, c.Space)
+}
+
+// validate checks that names are set and unique.
+func (d *Dashboards) validate() error {
+	spaces := make(map[string]bool, len(d.Spaces))
+	for _, s := range d.Spaces {
+		if s.Name == "" {
+			return fmt.Errorf("librato: dashboards: space without a name")
+		}
+		if spaces[s.Name] {
+			return fmt.Errorf("librato: dashboards: duplicate space %q", s.Name)
+		}
+		spaces[s.Name] = true
+
+		charts := make(map[string]bool, len(s.Charts))
+		for _, ch := range s.Charts {
+			if ch.Name == "" {
+				return fmt.Errorf("librato: dashboards: chart without a name in space %q", s.Name)
+			}
+			if charts[ch.Name] {
+				return fmt.Errorf("librato: dashboards: duplicate chart %q in space %q", ch.Name, s.Name)
+			}
+			charts[ch.Name] = true
+		}
+	}
+	return nil
+}
+
+// SyncDashboards makes the account's spaces and charts match defs, creating, updating
+// and deleting them as needed. It returns the changes made, which are also returned
+// up to the first failed request along with its error.
+func (c *TimeCollatedClient) SyncDashboards(ctx context.Context, defs *Dashboards) ([]DashboardChange, error) {
+	if err := defs.validate(); err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]Space)
+	it := c.Spaces(ctx, nil)
+	for it.Next() {
+		live[it.Value().Name] = it.Value()
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	var changes []DashboardChange
+	for _, def := range defs.Spaces {
+		space, ok := live[def.Name]
+		delete(live, def.Name)
+		if !ok {
+			created, err := c.CreateSpace(ctx, &Space{Name: def.Name})
+			if err != nil {
+				return changes, err
+			}
+			space = *created
+			space.Name = def.Name
+			changes = append(changes, DashboardChange{Action: "create", Space: def.Name})
+		}
+
+		cs, err := c.syncCharts(ctx, space, def.Charts, ok)
+		changes = append(changes, cs...)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	if defs.Prune {
+		for name, space := range live {
+			if err := c.DeleteSpace(ctx, space.ID); err != nil {
+				return changes, err
+			}
+			changes = append(changes, DashboardChange{Action: "delete", Space: name})
+		}
+	}
+	return changes, nil
+}
+
+// syncCharts makes the charts of space match defs. The charts of a new space aren't listed.
+func (c *TimeCollatedClient) syncCharts(ctx context.Context, space Space, defs []Chart, existing bool) ([]DashboardChange, error) {
+	live := make(map[string]Chart)
+	if existing {
+		charts, err := c.ListCharts(ctx, space.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range charts {
+			live[ch.Name] = ch
+		}
+	}
+
+	var changes []DashboardChange
+	for _, def := range defs {
+		def := def
+		cur, ok := live[def.Name]
+		delete(live, def.Name)
+
+		if !ok {
+			if _, err := c.CreateChart(ctx, space.ID, &def); err != nil {
+				return changes, err
+			}
+			changes = append(changes, DashboardChange{Action: "create", Space: space.Name, Chart: def.Name})
+			continue
+		}
+
+		diff, err := chartDiff(cur, def)
+		if err != nil {
+			return changes, err
+		}
+		if len(diff) > 0 {
+			def.ID = cur.ID
+			if err := c.UpdateChart(ctx, space.ID, &def); err != nil {
+				return changes, err
+			}
+			changes = append(changes, DashboardChange{Action: "update", Space: space.Name, Chart: def.Name})
+		}
+	}
+
+	for name, ch := range live {
+		if err := c.DeleteChart(ctx, space.ID, ch.ID); err != nil {
+			return changes, err
+		}
+		changes = append(changes, DashboardChange{Action: "delete", Space: space.Name, Chart: name})
+	}
+	return changes, nil
+}
+
+// chartDiff lists the fields set in the definition of a chart that differ in the live
+// one, ignoring the ID. Like with alerts, defaults filled in by Librato (e.g. the chart
+// type or the group function of streams) don't count. See jsonDiff().
+func chartDiff(live, def Chart) ([]string, error) {
+	live.ID, def.ID = 0, 0
+	if len(def.Streams) == 0 && len(live.Streams) == 0 {
+		live.Streams, def.Streams = nil, nil
+	}
+	return jsonDiff(live, def)
+}
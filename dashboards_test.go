@@ -0,0 +1,32 @@
+package librato
+
+import "testing"
+
+func TestChartDiffIgnoresDefaults(t *testing.T) {
+	def := Chart{Name: "Latency", Streams: []Stream{{Metric: "app.latency", Source: "*"}}}
+	live := Chart{
+		ID:      42,
+		Name:    "Latency",
+		Type:    "line",
+		Streams: []Stream{{Metric: "app.latency", Source: "*", GroupFunction: "average", SummaryFunction: "average"}},
+	}
+
+	diff, err := chartDiff(live, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Errorf("unexpected differences: %q", diff)
+	}
+
+	max := 100.0
+	def.Max = &max
+	def.Streams[0].GroupFunction = "sum"
+	diff, err = chartDiff(live, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 2 {
+		t.Errorf("got differences %q, want max and the group function", diff)
+	}
+}
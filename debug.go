@@ -0,0 +1,65 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// payloadCountKey is the context key of the number of measurements in a request.
+type payloadCountKey struct{}
+
+// withPayloadCount records the number of measurements in a request, for debug logging.
+func withPayloadCount(ctx context.Context, n int) context.Context {
+	if ctx.Value(payloadCountKey{}) == nil {
+		ctx = context.WithValue(ctx, payloadCountKey{}, n)
+	}
+	return ctx
+}
+
+// debugf logs a message to the debug writer, see WithDebug().
+func (c *TimeCollatedClient) debugf(format string, args ...interface{}) {
+	if c.debug == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("librato: %s "+format+"\n", append([]interface{}{time.Now().Format(time.RFC3339)}, args...)...)
+	c.debugMu.Lock()
+	defer c.debugMu.Unlock()
+	c.debug.Write([]byte(msg))
+}
+
+// debugRequest logs a request to the debug writer, see WithDebug(). Credentials are never
+// logged: they're only sent in the Authorization header, and the URL is redacted.
+// body is the response body of failed requests.
+func (c *TimeCollatedClient) debugRequest(req *http.Request, start time.Time, status int, body string, err error) {
+	if c.debug == nil {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "librato: %s %s %s", start.Format(time.RFC3339), req.Method, req.URL.Redacted())
+	if n, ok := req.Context().Value(payloadCountKey{}).(int); ok {
+		fmt.Fprintf(&b, " measurements=%d", n)
+	}
+	if req.ContentLength > 0 {
+		fmt.Fprintf(&b, " bytes=%d", req.ContentLength)
+	}
+	if status != 0 {
+		fmt.Fprintf(&b, " status=%d", status)
+	}
+	fmt.Fprintf(&b, " duration=%s", time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		fmt.Fprintf(&b, " error=%q", err)
+	}
+	if body != "" {
+		fmt.Fprintf(&b, " response=%q", body)
+	}
+	b.WriteByte('\n')
+
+	c.debugMu.Lock()
+	defer c.debugMu.Unlock()
+	c.debug.Write([]byte(b.String()))
+}
@@ -0,0 +1,121 @@
+package librato
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrDropped is the error of a Delivery whose measurement was dropped before it
+// was sent, e.g. by validation or a transform hook.
+var ErrDropped = errors.New("measurement was dropped")
+
+// Delivery tracks whether a measurement pushed with PushGaugeAck() or PushCounterAck()
+// made it to Librato. It's resolved once the batch containing the measurement is
+// accepted, or fails.
+type Delivery struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newDelivery() *Delivery {
+	return &Delivery{done: make(chan struct{})}
+}
+
+// Done returns a channel that's closed when the delivery is resolved.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err returns nil if the measurement was accepted, or the reason it wasn't.
+// It's only meaningful after Done() is closed.
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the delivery is resolved and returns its error,
+// or returns ctx.Err() if ctx is done first.
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolve sets the result of the delivery. Only the first call has an effect, and
+// it's a no-op on a nil Delivery, for measurements pushed without one.
+func (d *Delivery) resolve(err error) {
+	if d == nil {
+		return
+	}
+	d.once.Do(func() {
+		d.err = err
+		close(d.done)
+	})
+}
+
+// PushGaugeAck is like PushGauge(), but returns a Delivery that's resolved once the
+// value has been accepted by Librato, or has failed to be. It's meant for the few
+// measurements where fire and forget isn't acceptable. The value goes through the same
+// channel or dispatcher, backpressure and validation as any other, so once the client
+// is closed the Delivery fails with ErrDropped right away. If the gauge has an
+// aggregator, the Delivery is resolved as soon as the aggregator took the value.
+func (c *TimeCollatedClient) PushGaugeAck(name string, value interface{}) *Delivery {
+	return c.pushAck(KindGauge, name, value)
+}
+
+// PushCounterAck is like PushCounter(), but returns a Delivery. See PushGaugeAck().
+func (c *TimeCollatedClient) PushCounterAck(name string, value interface{}) *Delivery {
+	return c.pushAck(KindCounter, name, value)
+}
+
+// ackedValue is a value pushed with PushGaugeAck() or PushCounterAck(), carrying its
+// Delivery through the metric channel or shard to its measurement.
+type ackedValue struct {
+	value interface{}
+	ack   *Delivery
+}
+
+// unwrapAck returns the value of a pushed item, and its Delivery if it has one.
+func unwrapAck(item interface{}) (interface{}, *Delivery) {
+	if a, ok := item.(ackedValue); ok {
+		return a.value, a.ack
+	}
+	return item, nil
+}
+
+func (c *TimeCollatedClient) pushAck(kind MetricKind, name string, value interface{}) *Delivery {
+	d := newDelivery()
+	if err := c.pushContext(context.Background(), kind, name, ackedValue{value, d}); err != nil {
+		d.resolve(err)
+	}
+	return d
+}
+
+// pendingDeliveries returns the deliveries of the given measurements.
+func pendingDeliveries(lists ...[]Measurement) []*Delivery {
+	var ds []*Delivery
+	for _, ms := range lists {
+		for _, m := range ms {
+			if m.ack != nil {
+				ds = append(ds, m.ack)
+			}
+		}
+	}
+	return ds
+}
+
+// resolveDeliveries resolves the deliveries of the given measurements with err.
+func resolveDeliveries(err error, lists ...[]Measurement) {
+	for _, d := range pendingDeliveries(lists...) {
+		d.resolve(err)
+	}
+}
@@ -0,0 +1,48 @@
+package librato_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestPushGaugeAck(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []librato.Option
+	}{
+		{"channels", nil},
+		{"mpsc", []librato.Option{librato.WithMPSCChannels()}},
+		{"dispatcher", []librato.Option{librato.WithDispatcher(2)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := libratotest.NewServer()
+			defer srv.Close()
+			c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour, tc.opts...)
+			c.SetEndpoint(srv.Endpoint())
+
+			sent := c.PushGaugeAck("gauge", 1)
+			invalid := c.PushCounterAck("counter", math.NaN())
+			c.Close()
+			c.Wait()
+			closed := c.PushGaugeAck("gauge", 2)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := sent.Wait(ctx); err != nil {
+				t.Errorf("sent: %v", err)
+			}
+			if err := invalid.Wait(ctx); !errors.Is(err, librato.ErrDropped) {
+				t.Errorf("invalid: got %v, want ErrDropped", err)
+			}
+			if err := closed.Wait(ctx); !errors.Is(err, librato.ErrDropped) {
+				t.Errorf("after Close: got %v, want ErrDropped", err)
+			}
+		})
+	}
+}
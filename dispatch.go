@@ -0,0 +1,250 @@
+package librato
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// shard is a lock protected buffer of measurements, used in dispatcher mode.
+// See WithDispatcher().
+type shard struct {
+	mu    sync.Mutex
+	items []shardItem
+	spare []shardItem
+	// wake has a buffer of 1, so a worker is woken at most once per drain.
+	wake chan struct{}
+	// closed is set by close(), after which pushed measurements are dropped.
+	closed bool
+}
+
+// shardItem is a measurement buffered in a shard. Numbers pushed with PushInt(),
+// PushFloat() and PushCounterInt() are kept unboxed until the shard is drained, and
+// only run through the pipeline then, so pushing them doesn't allocate.
+type shardItem struct {
+	m Measurement
+	// unboxed is set if the value is f, or i if isInt is set, rather than m.Value.
+	unboxed, isInt bool
+	f              float64
+	i              int64
+}
+
+// close stops the shard's worker after it drains the shard one last time.
+func (s *shard) close() {
+	s.mu.Lock()
+	s.closed = true
+	close(s.wake)
+	s.mu.Unlock()
+}
+
+// PushGauge pushes a value (or a map of custom properties) for the named gauge.
+// It's equivalent to c.GetGauge(name).Input() <- value, unless dispatcher mode is
+// enabled. See WithDispatcher(). Boxing the value allocates, in either mode.
+func (c *TimeCollatedClient) PushGauge(name string, value interface{}) {
+	c.push(KindGauge, name, value)
+}
+
+// PushCounter pushes a value (or a map of custom properties) for the named counter.
+// It's equivalent to c.GetCounter(name).Input() <- value, unless dispatcher mode is
+// enabled. See WithDispatcher().
+func (c *TimeCollatedClient) PushCounter(name string, value interface{}) {
+	c.push(KindCounter, name, value)
+}
+
+// PushInt pushes an integer value for the named gauge. Integers are sent as they are,
+// without going through float64, so large values keep their precision.
+// In dispatcher mode it doesn't allocate, see WithDispatcher().
+func (c *TimeCollatedClient) PushInt(name string, v int64) {
+	c.pushNumber(shardItem{m: Measurement{Kind: KindGauge, Name: name}, unboxed: true, isInt: true, i: v})
+}
+
+// PushFloat pushes a floating point value for the named gauge.
+// In dispatcher mode it doesn't allocate, see WithDispatcher().
+func (c *TimeCollatedClient) PushFloat(name string, v float64) {
+	c.pushNumber(shardItem{m: Measurement{Kind: KindGauge, Name: name}, unboxed: true, f: v})
+}
+
+// PushCounterInt pushes an integer value for the named counter, see PushInt().
+// In dispatcher mode it doesn't allocate, see WithDispatcher().
+func (c *TimeCollatedClient) PushCounterInt(name string, v int64) {
+	c.pushNumber(shardItem{m: Measurement{Kind: KindCounter, Name: name}, unboxed: true, isInt: true, i: v})
+}
+
+// PushAt pushes a value for the named gauge that was observed at t, e.g. for backfilled
+// or delayed data. Otherwise measurements are stamped when the client processes them.
+// Times outside the window accepted by Librato are handled as set by WithTimestampWindow().
+func (c *TimeCollatedClient) PushAt(name string, value float64, t time.Time) {
+	c.push(KindGauge, name, map[string]interface{}{"value": value, "measure_time": t})
+}
+
+// PushCounterAt pushes a value for the named counter that was observed at t, see PushAt().
+func (c *TimeCollatedClient) PushCounterAt(name string, value int64, t time.Time) {
+	c.push(KindCounter, name, map[string]interface{}{"value": value, "measure_time": t})
+}
+
+// PushGaugeContext is like PushGauge(), but if pushing blocks because of WithBackpressure(),
+// it gives up once ctx is done and returns ctx.Err(). It returns ErrDropped if the client
+// is closed while waiting.
+func (c *TimeCollatedClient) PushGaugeContext(ctx context.Context, name string, value interface{}) error {
+	return c.pushContext(ctx, KindGauge, name, value)
+}
+
+// PushCounterContext is like PushCounter(), but gives up once ctx is done. See PushGaugeContext().
+func (c *TimeCollatedClient) PushCounterContext(ctx context.Context, name string, value interface{}) error {
+	return c.pushContext(ctx, KindCounter, name, value)
+}
+
+func (c *TimeCollatedClient) push(kind MetricKind, name string, value interface{}) {
+	c.pushContext(context.Background(), kind, name, value)
+}
+
+func (c *TimeCollatedClient) pushContext(ctx context.Context, kind MetricKind, name string, value interface{}) error {
+	if err := c.pressure.acquire(ctx); err != nil {
+		return err
+	}
+	c.enqueue(kind, name, value)
+	return nil
+}
+
+// enqueue hands a pushed value to its metric channel, or to a shard in dispatcher mode.
+func (c *TimeCollatedClient) enqueue(kind MetricKind, name string, value interface{}) {
+	if len(c.shards) == 0 {
+		m, ch := c.useMetric(kind, name)
+		if m == nil {
+			// The client is closed and ch discards the value.
+			_, ack := unwrapAck(value)
+			ack.resolve(ErrDropped)
+		}
+		// Push skips the Input() goroutine of MPSC channels, see WithMPSCChannels().
+		if p, ok := ch.(interface{ Push(interface{}) }); ok {
+			p.Push(value)
+		} else {
+			ch.Input() <- value
+		}
+		if m != nil {
+			m.release()
+		}
+		return
+	}
+
+	value, ack := unwrapAck(value)
+	if c.aggregate(kind, name, value) {
+		ack.resolve(nil)
+		return
+	}
+	m := c.newMeasurement(kind, name, value)
+	m.ack = ack
+	if !c.prepare(&m) {
+		c.pressure.release(1)
+		ack.resolve(ErrDropped)
+		return
+	}
+	c.bufferItem(shardItem{m: m})
+}
+
+// pushNumber pushes an unboxed number. Outside of dispatcher mode it's boxed right away.
+func (c *TimeCollatedClient) pushNumber(item shardItem) {
+	if c.pressure.acquire(context.Background()) != nil {
+		return
+	}
+	if len(c.shards) == 0 || c.aggregators.has(item.m.Kind, item.m.Name) {
+		item.box()
+		c.enqueue(item.m.Kind, item.m.Name, item.m.Value)
+		return
+	}
+
+	item.m.Source = *c.source.Load()
+	item.m.MeasureTime = c.clock.Now().Unix()
+	c.bufferItem(item)
+}
+
+// box sets the measurement value of an unboxed item.
+func (item *shardItem) box() {
+	if !item.unboxed {
+		return
+	}
+	if item.isInt {
+		item.m.Value = item.i
+	} else {
+		item.m.Value = item.f
+	}
+	item.unboxed = false
+}
+
+// bufferItem appends an item to its shard and wakes the shard's worker.
+func (c *TimeCollatedClient) bufferItem(item shardItem) {
+	s := c.shards[fnv32(item.m.Name)%uint32(len(c.shards))]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		c.pressure.release(1)
+		item.m.ack.resolve(ErrDropped)
+		return
+	}
+	s.items = append(s.items, item)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// The worker is already due to drain this shard.
+	}
+}
+
+func (c *TimeCollatedClient) startDispatchers() {
+	for i := range c.shards {
+		s := &shard{wake: make(chan struct{}, 1)}
+		c.shards[i] = s
+		c.wg.Add(1)
+		go c.dispatch(s)
+	}
+}
+
+// dispatch forwards buffered measurements of a shard to the collator until
+// the shard is closed, after which it drains the shard one last time.
+func (c *TimeCollatedClient) dispatch(s *shard) {
+	defer c.wg.Done()
+	for range s.wake {
+		c.drainShard(s)
+	}
+	c.drainShard(s)
+}
+
+func (c *TimeCollatedClient) drainShard(s *shard) {
+	s.mu.Lock()
+	items := s.items
+	// Swap the buffers to reuse their capacity and avoid allocations.
+	s.items, s.spare = s.spare[:0], nil
+	s.mu.Unlock()
+
+	for i := range items {
+		item := &items[i]
+		if item.unboxed {
+			item.box()
+			if !c.prepare(&item.m) {
+				c.pressure.release(1)
+				items[i] = shardItem{}
+				continue
+			}
+		}
+		if item.m.Kind == KindCounter {
+			c.collateCounters.Push(item.m)
+		} else {
+			c.collateGauges.Push(item.m)
+		}
+		items[i] = shardItem{}
+	}
+
+	s.mu.Lock()
+	s.spare = items[:0]
+	s.mu.Unlock()
+}
+
+// fnv32 is the 32-bit FNV-1a hash of s, without allocating.
+func fnv32(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
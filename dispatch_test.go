@@ -0,0 +1,63 @@
+package librato_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+// BenchmarkPush measures pushes to a single gauge, including the work of the metric
+// goroutines or dispatcher workers and of the collator, as allocations anywhere are
+// counted. Flushes are left out: a client only flushes when it's closed, after every
+// 1<<16 pushes, with the timer stopped. Only Dispatcher/PushFloat and Dispatcher/PushInt
+// should report 0 allocs/op. Their B/op is the amortized growth of the collator's buffers,
+// which takes less than one allocation per push.
+func BenchmarkPush(b *testing.B) {
+	pushes := []struct {
+		name string
+		push func(c *librato.TimeCollatedClient, i int)
+	}{
+		{"PushGauge", func(c *librato.TimeCollatedClient, i int) { c.PushGauge("bench", float64(i)) }},
+		{"PushFloat", func(c *librato.TimeCollatedClient, i int) { c.PushFloat("bench", float64(i)) }},
+		{"PushInt", func(c *librato.TimeCollatedClient, i int) { c.PushInt("bench", int64(i)) }},
+	}
+	for _, mode := range []struct {
+		name string
+		opts []librato.Option
+	}{
+		{"Channels", nil},
+		{"MPSC", []librato.Option{librato.WithMPSCChannels()}},
+		{"Dispatcher", []librato.Option{librato.WithDispatcher(4)}},
+	} {
+		for _, p := range pushes {
+			b.Run(mode.name+"/"+p.name, func(b *testing.B) {
+				srv := libratotest.NewServer()
+				defer srv.Close()
+
+				var c *librato.TimeCollatedClient
+				stop := func() {
+					if c != nil {
+						c.Close()
+						c.Wait()
+						srv.Reset()
+					}
+				}
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if i%(1<<16) == 0 {
+						b.StopTimer()
+						stop()
+						c = librato.NewTimeCollatedClient("user", "token", "source", time.Hour, mode.opts...)
+						c.SetEndpoint(srv.Endpoint())
+						b.StartTimer()
+					}
+					p.push(c, i)
+				}
+				b.StopTimer()
+				stop()
+			})
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package librato
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Encoder encodes request payloads as JSON. It can be used to plug in faster
+// JSON implementations, see WithEncoder(). It must be safe for concurrent use.
+type Encoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// EncoderFunc is an adapter to use ordinary functions as Encoders.
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+func (f EncoderFunc) Encode(w io.Writer, v interface{}) error {
+	return f(w, v)
+}
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		b := &encodeBuffer{}
+		b.enc = json.NewEncoder(&b.Buffer)
+		b.enc.SetEscapeHTML(false)
+		return b
+	},
+}
+
+// encodeBuffer is a pooled buffer with a JSON encoder writing to it. Batches are
+// streamed into it rather than marshaled into a new slice for every request.
+// Requests read it through bodies, see body(), so that they can be replayed.
+type encodeBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+	// refs counts the owner and the open bodies. The buffer goes back to the pool
+	// once it drops to 0.
+	refs   atomic.Int32
+	closed atomic.Bool
+}
+
+func getEncodeBuffer() *encodeBuffer {
+	b := encodeBuffers.Get().(*encodeBuffer)
+	b.refs.Store(1)
+	b.closed.Store(false)
+	return b
+}
+
+// Close releases the owner's reference, returning the buffer to the pool once the
+// bodies of its requests are closed too. Only the first call has an effect.
+func (b *encodeBuffer) Close() error {
+	if b.closed.CompareAndSwap(false, true) {
+		b.release()
+	}
+	return nil
+}
+
+func (b *encodeBuffer) release() {
+	if b.refs.Add(-1) == 0 {
+		b.Reset()
+		encodeBuffers.Put(b)
+	}
+}
+
+// body returns a request body reading the encoded bytes. The HTTP transport closes it
+// once it's done, possibly after the request returned, and may ask for another one
+// through Request.GetBody to replay the request, e.g. on a dead keep-alive connection.
+func (b *encodeBuffer) body() io.ReadCloser {
+	b.refs.Add(1)
+	return &bufferBody{Reader: bytes.NewReader(b.Bytes()), buf: b}
+}
+
+// bufferBody is a request body reading an encodeBuffer.
+type bufferBody struct {
+	*bytes.Reader
+	buf  *encodeBuffer
+	once sync.Once
+}
+
+func (r *bufferBody) Close() error {
+	r.once.Do(r.buf.release)
+	return nil
+}
+
+// encode encodes v into a pooled buffer, using the custom encoder if there's one.
+// The buffer must be closed once the requests using it are done.
+func (c *TimeCollatedClient) encode(v interface{}) (*encodeBuffer, error) {
+	buf := getEncodeBuffer()
+	var err error
+	if c.encoder != nil {
+		err = c.encoder.Encode(&buf.Buffer, v)
+	} else {
+		err = buf.enc.Encode(v)
+	}
+	if err != nil {
+		buf.Close()
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,121 @@
+package librato
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// benchmarkBatch returns a batch of MaxMetrics measurements, split between gauges and counters.
+func benchmarkBatch() *Batch {
+	batch := &Batch{}
+	for i := 0; i < MaxMetrics/2; i++ {
+		batch.Gauges = append(batch.Gauges, Measurement{
+			Kind:        KindGauge,
+			Name:        fmt.Sprintf("app.gauge.%d", i),
+			Value:       float64(i) * 1.5,
+			Source:      "host-1",
+			MeasureTime: 1700000000,
+		})
+		batch.Counters = append(batch.Counters, Measurement{
+			Kind:        KindCounter,
+			Name:        fmt.Sprintf("app.counter.%d", i),
+			Value:       int64(i),
+			Source:      "host-1",
+			MeasureTime: 1700000000,
+		})
+	}
+	return batch
+}
+
+func BenchmarkEncode(b *testing.B) {
+	batch := benchmarkBatch()
+
+	b.Run("pooled", func(b *testing.B) {
+		c := newClient("user", "token", "source", time.Minute, nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := c.encode(batch)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf.Close()
+		}
+	})
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(batch)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// Mirrors what postBatch used to do with the result.
+			_ = bytes.NewBuffer(data)
+		}
+	})
+}
+
+func BenchmarkPostBatch(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	c := newClient("user", "token", "source", time.Minute, nil)
+	c.SetEndpoint(srv.URL)
+	batch := benchmarkBatch()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.postBatch(ctx, batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeBufferBodies(t *testing.T) {
+	c := NewTimeCollatedClient("user", "token", "source", time.Hour)
+	defer c.Close()
+
+	buf, err := c.encode(map[string]int{"value": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := c.newRequest(context.Background(), http.MethodPost, "http://localhost/", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody == nil {
+		t.Fatal("request can't be replayed")
+	}
+
+	want, _ := io.ReadAll(req.Body)
+	// The transport closes the body before asking for another one, and may close
+	// bodies more than once.
+	req.Body.Close()
+	req.Body.Close()
+	replay, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(replay)
+	if !bytes.Equal(got, want) || int64(len(got)) != req.ContentLength {
+		t.Errorf("replayed %q, want %q of length %d", got, want, req.ContentLength)
+	}
+
+	replay.Close()
+	buf.Close()
+	buf.Close()
+	if n := buf.refs.Load(); n != 0 {
+		t.Errorf("%d references left after closing everything", n)
+	}
+}
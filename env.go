@@ -0,0 +1,92 @@
+package librato
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultFlushInterval is the flush interval of clients created by NewClientFromEnv()
+// when LIBRATO_FLUSH_INTERVAL isn't set.
+const DefaultFlushInterval = 10 * time.Second
+
+// NewClientFromEnv creates a client configured by environment variables:
+//
+//	LIBRATO_USER            required
+//	LIBRATO_TOKEN           required
+//	LIBRATO_SOURCE          optional, defaults to the hostname
+//	LIBRATO_FLUSH_INTERVAL  optional, e.g. "30s", defaults to DefaultFlushInterval
+//	LIBRATO_TAGS            optional, e.g. "env=prod,region=us-east-1", see WithDefaultTags()
+//	LIBRATO_ALLOW           optional, comma separated name patterns, see NewNameFilter()
+//	LIBRATO_DENY            optional, comma separated name patterns
+//
+// Commas inside /regexp/ patterns don't separate them, e.g. "/^exp\.[0-9]{1,3}$/,db.*".
+//
+// It returns a descriptive error if a required variable is missing or a value is invalid.
+func NewClientFromEnv(opts ...Option) (*TimeCollatedClient, error) {
+	user := os.Getenv("LIBRATO_USER")
+	if user == "" {
+		return nil, fmt.Errorf("librato: LIBRATO_USER is not set")
+	}
+	token := os.Getenv("LIBRATO_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("librato: LIBRATO_TOKEN is not set")
+	}
+
+	cfg := Config{
+		User:          user,
+		Token:         token,
+		Source:        os.Getenv("LIBRATO_SOURCE"),
+		FlushInterval: Duration(DefaultFlushInterval),
+	}
+	if v := os.Getenv("LIBRATO_FLUSH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("librato: invalid LIBRATO_FLUSH_INTERVAL %q: %w", v, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("librato: LIBRATO_FLUSH_INTERVAL must be positive, got %q", v)
+		}
+		cfg.FlushInterval = Duration(d)
+	}
+
+	if v := os.Getenv("LIBRATO_TAGS"); v != "" {
+		tags, err := ParseTags(v)
+		if err != nil {
+			return nil, fmt.Errorf("librato: invalid LIBRATO_TAGS: %w", err)
+		}
+		cfg.Tags = tags
+	}
+
+	if v := os.Getenv("LIBRATO_ALLOW"); v != "" {
+		cfg.Allow = splitPatterns(v)
+	}
+	if v := os.Getenv("LIBRATO_DENY"); v != "" {
+		cfg.Deny = splitPatterns(v)
+	}
+
+	return NewClientFromConfig(cfg, opts...)
+}
+
+// splitPatterns splits a comma separated list of name patterns. A /regexp/ pattern
+// only ends at a slash followed by a comma (or the end), so it can contain commas.
+func splitPatterns(s string) []string {
+	var patterns []string
+	for s != "" {
+		end := strings.IndexByte(s, ',')
+		if strings.HasPrefix(s, "/") {
+			end = strings.Index(s[1:], "/,")
+			if end >= 0 {
+				end += 2
+			}
+		}
+		if end < 0 {
+			patterns = append(patterns, s)
+			break
+		}
+		patterns = append(patterns, s[:end])
+		s = s[end+1:]
+	}
+	return patterns
+}
@@ -0,0 +1,22 @@
+package librato
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"a.*,b", []string{"a.*", "b"}},
+		{`/^exp\.[0-9]{1,3}$/`, []string{`/^exp\.[0-9]{1,3}$/`}},
+		{`db.*,/^exp\.[0-9]{1,3}$/,/x{2,}/`, []string{"db.*", `/^exp\.[0-9]{1,3}$/`, "/x{2,}/"}},
+		{`/a,b/,c`, []string{"/a,b/", "c"}},
+	} {
+		if got := splitPatterns(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitPatterns(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package librato
+
+import "sync"
+
+// EWMA is an Aggregator that smooths the values of a metric with an exponentially
+// weighted moving average, and reports the average as a gauge at every flush. Once it
+// has a value, it's reported even for intervals without new ones. Values that aren't
+// numbers are ignored. It's safe for concurrent use.
+//
+//	c.SetGaugeAggregator("latency", librato.NewEWMA(0.2))
+type EWMA struct {
+	alpha float64
+
+	mu    sync.Mutex
+	value float64
+	set   bool
+}
+
+// NewEWMA returns an EWMA where each new value has a weight of alpha, between 0 and 1.
+// Higher values follow changes faster, lower values smooth them more. Alpha is clamped
+// to that range.
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{alpha: min(max(alpha, 0), 1)}
+}
+
+// Add updates the average with a pushed value, see Aggregator.
+func (e *EWMA) Add(value interface{}) {
+	if f, ok := toFloat64(value); ok {
+		e.Update(f)
+	}
+}
+
+// Update updates the average with v. The first value is taken as it is.
+func (e *EWMA) Update(v float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.set {
+		e.value, e.set = v, true
+		return
+	}
+	e.value += e.alpha * (v - e.value)
+}
+
+// Value returns the current average, and false if there were no values yet.
+func (e *EWMA) Value() (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.value, e.set
+}
+
+// Flush returns the current average as a gauge, see Aggregator.
+func (e *EWMA) Flush() []Measurement {
+	v, ok := e.Value()
+	if !ok {
+		return nil
+	}
+	return []Measurement{{Kind: KindGauge, Value: v}}
+}
@@ -0,0 +1,105 @@
+package librato
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ExportFormat is the output format of Export().
+type ExportFormat int
+
+const (
+	// ExportCSV writes a header row and a "metric,source,time,value" row per point.
+	// Times are in RFC 3339, in UTC.
+	ExportCSV ExportFormat = iota
+	// ExportJSON writes a JSON object per point and line, with the same fields as ExportCSV.
+	ExportJSON
+)
+
+// exportRow is a point of a series, as written by Export().
+type exportRow struct {
+	Metric string    `json:"metric"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	Value  float64   `json:"value"`
+}
+
+// Export runs a query and writes its result to w, e.g. for offline analysis or archival.
+// See WriteCSV() and WriteJSON() to write results that were already fetched.
+func (c *TimeCollatedClient) Export(ctx context.Context, w io.Writer, q ComposeQuery, format ExportFormat) error {
+	res, err := c.Compose(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case ExportCSV:
+		return WriteCSV(w, res)
+	case ExportJSON:
+		return WriteJSON(w, res)
+	default:
+		return fmt.Errorf("librato: unknown export format %d", format)
+	}
+}
+
+// WriteCSV writes the points of a query result to w in the ExportCSV format.
+func WriteCSV(w io.Writer, res *ComposeResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"metric", "source", "time", "value"}); err != nil {
+		return err
+	}
+	err := res.each(func(r exportRow) error {
+		return cw.Write([]string{
+			r.Metric,
+			r.Source,
+			r.Time.Format(time.RFC3339),
+			formatValue(r.Value),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatValue formats v without an exponent if it's an integer, e.g. "1000000"
+// rather than "1e+06", the way encoding/json does.
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteJSON writes the points of a query result to w in the ExportJSON format.
+func WriteJSON(w io.Writer, res *ComposeResult) error {
+	enc := json.NewEncoder(w)
+	return res.each(func(r exportRow) error {
+		return enc.Encode(r)
+	})
+}
+
+// each calls fn for every point of every series, in order, until it returns an error.
+func (res *ComposeResult) each(fn func(exportRow) error) error {
+	for _, s := range res.Series {
+		for _, p := range s.Points {
+			err := fn(exportRow{
+				Metric: s.Metric.Name,
+				Source: s.Source.Name,
+				Time:   p.Time().UTC(),
+				Value:  p.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failover is a secondary endpoint that batches are sent to while the primary one
+// keeps failing, e.g. a relay or a mirror ingest. See WithFailover().
+type Failover struct {
+	// Endpoint is the secondary API endpoint. User and Token default to the
+	// credentials of the client.
+	Endpoint    string
+	User, Token string
+	// Threshold is the number of consecutive failed requests after which the client
+	// switches to the secondary endpoint. Defaults to 3.
+	Threshold int
+	// ProbeInterval is how often the primary endpoint is checked with a Ping() while
+	// the secondary one is in use. The client switches back once it responds.
+	// Defaults to a minute.
+	ProbeInterval time.Duration
+	// OnRecover is called when the client switches back to the primary endpoint.
+	// Switching to the secondary one is reported as an error, but this isn't.
+	OnRecover func()
+}
+
+// failoverState tracks which endpoint batches are sent to.
+type failoverState struct {
+	Failover
+
+	mu        sync.Mutex
+	active    bool // whether the secondary endpoint is in use
+	failures  int
+	lastProbe time.Time
+}
+
+func newFailoverState(f Failover) *failoverState {
+	f.Endpoint = strings.TrimSuffix(f.Endpoint, "/")
+	if f.Threshold <= 0 {
+		f.Threshold = 3
+	}
+	if f.ProbeInterval <= 0 {
+		f.ProbeInterval = time.Minute
+	}
+	return &failoverState{Failover: f}
+}
+
+// post sends data to path under the primary endpoint, or the secondary one if the
+// client failed over. If data is an io.Closer, it's closed once the request is done.
+func (c *TimeCollatedClient) post(ctx context.Context, data io.Reader, path string) error {
+	f := c.failover
+	if f == nil {
+		return c.makeRequest(ctx, data, c.endpoint+path)
+	}
+
+	if !c.useSecondary(ctx) {
+		err := c.makeRequest(ctx, data, c.endpoint+path)
+		f.record(c, err)
+		return err
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodPost, f.Endpoint+path, data)
+	if err != nil {
+		return err
+	}
+	if f.User != "" || f.Token != "" {
+		req.SetBasicAuth(f.User, f.Token)
+	}
+	return c.do(req, nil)
+}
+
+// useSecondary reports whether to send to the secondary endpoint, probing
+// the primary one if it's time to.
+func (c *TimeCollatedClient) useSecondary(ctx context.Context) bool {
+	f := c.failover
+	f.mu.Lock()
+	if !f.active {
+		f.mu.Unlock()
+		return false
+	}
+	now := c.clock.Now()
+	if now.Sub(f.lastProbe) < f.ProbeInterval {
+		f.mu.Unlock()
+		return true
+	}
+	f.lastProbe = now
+	f.mu.Unlock()
+
+	if err := c.Ping(ctx); err != nil {
+		return true
+	}
+
+	f.mu.Lock()
+	recovered := f.active
+	f.active = false
+	f.failures = 0
+	f.mu.Unlock()
+
+	if recovered {
+		c.debugf("failover: %s is back, switching to it", c.endpoint)
+		if f.OnRecover != nil {
+			f.OnRecover()
+		}
+	}
+	return false
+}
+
+// record counts consecutive failures of the primary endpoint, switching to the
+// secondary one once there are too many.
+func (f *failoverState) record(c *TimeCollatedClient, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil || !retryable(err) {
+		f.failures = 0
+		return
+	}
+	f.failures++
+	if !f.active && f.failures >= f.Threshold {
+		f.active = true
+		f.lastProbe = c.clock.Now()
+		c.reportError(fmt.Errorf("failover: %d consecutive failures, switching to %s: %w", f.failures, f.Endpoint, err))
+	}
+}
+
+// FailedOver reports whether batches are currently sent to the failover endpoint.
+func (c *TimeCollatedClient) FailedOver() bool {
+	if c.failover == nil {
+		return false
+	}
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	return c.failover.active
+}
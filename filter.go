@@ -0,0 +1,78 @@
+package librato
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NameFilter decides which metrics are submitted based on their names.
+// See WithNameFilter().
+type NameFilter struct {
+	allow, deny []func(string) bool
+}
+
+// NewNameFilter compiles allow and deny patterns. A name is allowed if it matches
+// any allow pattern (or there are none) and no deny pattern. Patterns are globs as
+// in path.Match (e.g. "api.*.latency"), or regular expressions if they're enclosed
+// in slashes (e.g. "/^debug\./").
+func NewNameFilter(allow, deny []string) (*NameFilter, error) {
+	f := &NameFilter{}
+	var err error
+	if f.allow, err = compilePatterns(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = compilePatterns(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]func(string) bool, error) {
+	var matchers []func(string) bool
+	for _, p := range patterns {
+		if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			matchers = append(matchers, re.MatchString)
+			continue
+		}
+
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		p := p
+		matchers = append(matchers, func(name string) bool {
+			ok, _ := path.Match(p, name)
+			return ok
+		})
+	}
+	return matchers, nil
+}
+
+// Allowed reports whether metrics with the given name should be submitted.
+func (f *NameFilter) Allowed(name string) bool {
+	for _, match := range f.deny {
+		if match(name) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, match := range f.allow {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNameFilter replaces the name filter at runtime, see WithNameFilter().
+// A nil filter allows every name.
+func (c *TimeCollatedClient) SetNameFilter(f *NameFilter) {
+	c.filter.Store(f)
+}
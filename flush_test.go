@@ -0,0 +1,29 @@
+package librato
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock that's always at the same time.
+type fixedClock struct {
+	RealClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestAlignedFlushFollowsUnixEpoch(t *testing.T) {
+	// 7s doesn't divide a day, so Go's zero time and the Unix epoch are out of phase.
+	const interval = 7 * time.Second
+	now := time.Unix(1_700_000_003, 0)
+	c := &TimeCollatedClient{clock: fixedClock{now: now}, align: true}
+	c.duration.Store(int64(interval))
+
+	next := now.Add(c.nextFlush(false))
+	if next.Unix()%7 != 0 || next.Sub(now) > interval {
+		t.Errorf("next flush at %d, want the next multiple of 7s after %d", next.Unix(), now.Unix())
+	}
+}
@@ -0,0 +1,82 @@
+// Package hostmetrics collects host CPU, memory, disk and network stats, turning any
+// Go binary into a lightweight Librato agent:
+//
+//	stop := client.Collect(hostmetrics.New(), time.Minute)
+//
+// It's only supported on Linux, elsewhere Collect returns ErrUnsupported.
+package hostmetrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned by Collect on platforms other than Linux.
+var ErrUnsupported = errors.New("hostmetrics: unsupported platform")
+
+// Collector implements librato.Collector. Values are reported as gauges named:
+//
+//	<prefix>cpu.user, cpu.system, cpu.iowait, cpu.idle   percent of CPU time
+//	<prefix>load.1, load.5, load.15                      load averages
+//	<prefix>memory.total, memory.available, memory.used bytes
+//	<prefix>swap.total, swap.used                        bytes
+//	<prefix>disk.<name>.total, .free, .used_percent      bytes and percent
+//	<prefix>net.<iface>.rx_bytes, .tx_bytes              bytes per second
+//
+// CPU and network stats are rates, so they're only reported from the second call.
+type Collector struct {
+	// Prefix is prepended to metric names. Defaults to "host.".
+	Prefix string
+	// Disks are mount points to report, by name. Defaults to the root filesystem as "root".
+	Disks map[string]string
+	// Interfaces to report. Defaults to every interface except loopback.
+	Interfaces []string
+
+	// ProcRoot is where procfs is mounted, e.g. to read the host's from a container.
+	// Defaults to /proc.
+	ProcRoot string
+
+	mu   sync.Mutex
+	prev *sample
+}
+
+// sample holds cumulative counters from the previous call, to compute rates.
+type sample struct {
+	at  time.Time
+	cpu []uint64
+	net map[string][2]uint64
+}
+
+// New returns a Collector with the default settings.
+func New() *Collector {
+	return &Collector{}
+}
+
+// Collect samples the stats of the host.
+func (c *Collector) Collect() (map[string]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.collect()
+}
+
+func (c *Collector) prefix() string {
+	if c.Prefix == "" {
+		return "host."
+	}
+	return c.Prefix
+}
+
+func (c *Collector) procRoot() string {
+	if c.ProcRoot == "" {
+		return "/proc"
+	}
+	return c.ProcRoot
+}
+
+func (c *Collector) disks() map[string]string {
+	if len(c.Disks) == 0 {
+		return map[string]string{"root": "/"}
+	}
+	return c.Disks
+}
@@ -0,0 +1,210 @@
+//go:build linux
+
+package hostmetrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func (c *Collector) collect() (map[string]float64, error) {
+	p := c.prefix()
+	values := make(map[string]float64)
+	cur := &sample{at: time.Now()}
+	var errs []error
+
+	if cpu, err := c.readCPU(); err != nil {
+		errs = append(errs, err)
+	} else {
+		cur.cpu = cpu
+		if c.prev != nil && len(c.prev.cpu) == len(cpu) {
+			cpuPercents(values, p, c.prev.cpu, cpu)
+		}
+	}
+
+	if err := c.readLoad(values, p); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.readMemory(values, p); err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, path := range c.disks() {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			errs = append(errs, fmt.Errorf("disk %s: %w", path, err))
+			continue
+		}
+		total := float64(st.Blocks) * float64(st.Bsize)
+		free := float64(st.Bavail) * float64(st.Bsize)
+		values[p+"disk."+name+".total"] = total
+		values[p+"disk."+name+".free"] = free
+		if total > 0 {
+			values[p+"disk."+name+".used_percent"] = 100 * (total - float64(st.Bfree)*float64(st.Bsize)) / total
+		}
+	}
+
+	if net, err := c.readNet(); err != nil {
+		errs = append(errs, err)
+	} else {
+		cur.net = net
+		if c.prev != nil && c.prev.net != nil {
+			secs := cur.at.Sub(c.prev.at).Seconds()
+			for iface, n := range net {
+				if prev, ok := c.prev.net[iface]; ok && secs > 0 && n[0] >= prev[0] && n[1] >= prev[1] {
+					values[p+"net."+iface+".rx_bytes"] = float64(n[0]-prev[0]) / secs
+					values[p+"net."+iface+".tx_bytes"] = float64(n[1]-prev[1]) / secs
+				}
+			}
+		}
+	}
+
+	c.prev = cur
+	return values, errors.Join(errs...)
+}
+
+// readCPU returns the aggregate CPU time counters of /proc/stat: user, nice,
+// system, idle, iowait, irq, softirq and steal.
+func (c *Collector) readCPU() ([]uint64, error) {
+	f, err := os.Open(filepath.Join(c.procRoot(), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+		cpu := make([]uint64, 8)
+		for i := range cpu {
+			if cpu[i], err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+				return nil, fmt.Errorf("stat: %w", err)
+			}
+		}
+		return cpu, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("stat: no cpu line")
+}
+
+func cpuPercents(values map[string]float64, p string, prev, cur []uint64) {
+	var total uint64
+	delta := make([]uint64, len(cur))
+	for i := range cur {
+		if cur[i] >= prev[i] {
+			delta[i] = cur[i] - prev[i]
+		}
+		total += delta[i]
+	}
+	if total == 0 {
+		return
+	}
+	percent := func(v uint64) float64 {
+		return 100 * float64(v) / float64(total)
+	}
+	values[p+"cpu.user"] = percent(delta[0] + delta[1])
+	values[p+"cpu.system"] = percent(delta[2] + delta[5] + delta[6])
+	values[p+"cpu.idle"] = percent(delta[3])
+	values[p+"cpu.iowait"] = percent(delta[4])
+}
+
+func (c *Collector) readLoad(values map[string]float64, p string) error {
+	b, err := os.ReadFile(filepath.Join(c.procRoot(), "loadavg"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 3 {
+		return errors.New("loadavg: unexpected format")
+	}
+	for i, name := range []string{"load.1", "load.5", "load.15"} {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return fmt.Errorf("loadavg: %w", err)
+		}
+		values[p+name] = v
+	}
+	return nil
+}
+
+func (c *Collector) readMemory(values map[string]float64, p string) error {
+	f, err := os.Open(filepath.Join(c.procRoot(), "meminfo"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Values are in kB.
+	info := make(map[string]float64)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			info[strings.TrimSuffix(fields[0], ":")] = v * 1024
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	total, available := info["MemTotal"], info["MemAvailable"]
+	values[p+"memory.total"] = total
+	values[p+"memory.available"] = available
+	values[p+"memory.used"] = total - available
+	values[p+"swap.total"] = info["SwapTotal"]
+	values[p+"swap.used"] = info["SwapTotal"] - info["SwapFree"]
+	return nil
+}
+
+// readNet returns received and transmitted bytes by interface.
+func (c *Collector) readNet() (map[string][2]uint64, error) {
+	f, err := os.Open(filepath.Join(c.procRoot(), "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	include := make(map[string]bool)
+	for _, iface := range c.Interfaces {
+		include[iface] = true
+	}
+
+	net := make(map[string][2]uint64)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		iface, stats, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue // header
+		}
+		iface = strings.TrimSpace(iface)
+		if len(include) > 0 && !include[iface] || len(include) == 0 && iface == "lo" {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, err1 := strconv.ParseUint(fields[0], 10, 64)
+		tx, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		net[iface] = [2]uint64{rx, tx}
+	}
+	return net, s.Err()
+}
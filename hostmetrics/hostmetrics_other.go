@@ -0,0 +1,7 @@
+//go:build !linux
+
+package hostmetrics
+
+func (c *Collector) collect() (map[string]float64, error) {
+	return nil, ErrUnsupported
+}
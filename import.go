@@ -0,0 +1,226 @@
+package librato
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ColumnMapping describes the columns of a CSV file imported by ImportCSV(). Columns are
+// identified by their name in the header row, which must be the first row of the file.
+type ColumnMapping struct {
+	// Name is the column with metric names. If it's empty, every row is a
+	// measurement of the metric named by Metric.
+	Name   string
+	Metric string
+	// Value and Time are required. Times are parsed with TimeLayout, or as Unix
+	// seconds if it's empty.
+	Value      string
+	Time       string
+	TimeLayout string
+	// Source and Tags are optional. Tags are in the format accepted by ParseTags(),
+	// and only sent in tagged mode, see WithDefaultTags().
+	Source string
+	Tags   string
+	// Kind is the kind of all imported measurements. Counter values must be integers.
+	Kind MetricKind
+	// RequestsPerSecond limits the rate of requests made by the import. 0 means no limit.
+	// Requests rejected with 429 - Too Many Requests are retried after a backoff regardless.
+	RequestsPerSecond float64
+}
+
+// columns holds the indexes of the mapped columns, or -1 for unmapped ones.
+type columns struct {
+	name, value, time, source, tags int
+}
+
+func (m ColumnMapping) columns(header []string) (columns, error) {
+	index := func(name string, required bool) (int, error) {
+		if name == "" {
+			if required {
+				return -1, errors.New("missing column mapping")
+			}
+			return -1, nil
+		}
+		for i, h := range header {
+			if h == name {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("no column named %q", name)
+	}
+
+	var cols columns
+	var err error
+	if m.Name == "" && m.Metric == "" {
+		return cols, errors.New("either Name or Metric must be set")
+	}
+	if cols.name, err = index(m.Name, false); err != nil {
+		return cols, err
+	}
+	if cols.value, err = index(m.Value, true); err != nil {
+		return cols, fmt.Errorf("value: %w", err)
+	}
+	if cols.time, err = index(m.Time, true); err != nil {
+		return cols, fmt.Errorf("time: %w", err)
+	}
+	if cols.source, err = index(m.Source, false); err != nil {
+		return cols, err
+	}
+	if cols.tags, err = index(m.Tags, false); err != nil {
+		return cols, err
+	}
+	return cols, nil
+}
+
+// measurement parses a CSV row into a measurement.
+func (m ColumnMapping) measurement(cols columns, row []string) (Measurement, error) {
+	ms := Measurement{Kind: m.Kind, Name: m.Metric}
+	if cols.name >= 0 {
+		ms.Name = row[cols.name]
+	}
+	if cols.source >= 0 {
+		ms.Source = row[cols.source]
+	}
+
+	value := row[cols.value]
+	if m.Kind == KindCounter {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return ms, fmt.Errorf("invalid counter value %q", value)
+		}
+		ms.Value = v
+	} else {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ms, fmt.Errorf("invalid gauge value %q", value)
+		}
+		ms.Value = v
+	}
+
+	at := row[cols.time]
+	if m.TimeLayout == "" {
+		t, err := strconv.ParseInt(at, 10, 64)
+		if err != nil {
+			return ms, fmt.Errorf("invalid time %q, expected Unix seconds", at)
+		}
+		ms.MeasureTime = t
+	} else {
+		t, err := time.Parse(m.TimeLayout, at)
+		if err != nil {
+			return ms, fmt.Errorf("invalid time %q: %w", at, err)
+		}
+		ms.SetTime(t)
+	}
+
+	if cols.tags >= 0 && row[cols.tags] != "" {
+		tags, err := ParseTags(row[cols.tags])
+		if err != nil {
+			return ms, err
+		}
+		ms.Tags = tags
+	}
+	return ms, nil
+}
+
+// ImportCSV streams historical measurements from a CSV file to Librato, e.g. to backfill
+// data from legacy systems. Measurements keep the time of their row and are posted in
+// batches of up to the maximum batch size (see SetMaxBatchSize()), paced as set by the
+// mapping. Like Replay(), it always posts to the Librato API, even if a custom sink is set.
+//
+// It stops at the first invalid row or failed batch, returning its error. Batches before
+// it were already posted.
+func (c *TimeCollatedClient) ImportCSV(ctx context.Context, r io.Reader, mapping ColumnMapping) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("import: header: %w", err)
+	}
+	cols, err := mapping.columns(header)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	var pace *time.Ticker
+	if mapping.RequestsPerSecond > 0 {
+		pace = time.NewTicker(time.Duration(float64(time.Second) / mapping.RequestsPerSecond))
+		defer pace.Stop()
+	}
+	post := func(ms []Measurement) error {
+		if pace != nil {
+			select {
+			case <-pace.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		batch := &Batch{ID: newBatchID()}
+		if mapping.Kind == KindCounter {
+			batch.Counters = ms
+		} else {
+			batch.Gauges = ms
+		}
+		return c.postRateLimited(ctx, batch)
+	}
+
+	max := int(c.maxBatch.Load())
+	ms := make([]Measurement, 0, max)
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+
+		m, err := mapping.measurement(cols, row)
+		if err == nil {
+			err = c.validate(&m)
+		}
+		if err != nil {
+			return fmt.Errorf("import: line %d: %w", line, err)
+		}
+
+		ms = append(ms, m)
+		if len(ms) == max {
+			if err := post(ms); err != nil {
+				return fmt.Errorf("import: line %d: %w", line, err)
+			}
+			ms = make([]Measurement, 0, max)
+		}
+	}
+	if len(ms) > 0 {
+		if err := post(ms); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+	return nil
+}
+
+// postRateLimited posts a batch to the Librato API, retrying it with an exponential
+// backoff for as long as it's rejected with 429 - Too Many Requests, until ctx is done.
+func (c *TimeCollatedClient) postRateLimited(ctx context.Context, batch *Batch) error {
+	backoff := time.Second
+	for {
+		err := c.postBatch(ctx, batch)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
@@ -0,0 +1,79 @@
+package librato
+
+import (
+	"regexp"
+	"time"
+)
+
+// MetricInterval sends matching metrics less often than the flush interval, e.g. every
+// 5 minutes for billing counters while latency gauges are sent every 10 seconds.
+// See WithMetricIntervals().
+type MetricInterval struct {
+	// Name is matched against metric names. A nil expression matches every metric.
+	Name *regexp.Regexp
+	// Interval is how often matching measurements are sent. It's rounded up to the
+	// next flush, so intervals shorter than the flush interval have no effect.
+	// Rules with an Interval of 0 or less are ignored.
+	Interval time.Duration
+}
+
+// heldBucket collates the measurements of a MetricInterval until it's due.
+type heldBucket struct {
+	due              time.Time
+	gauges, counters []Measurement
+}
+
+// holdBack moves measurements of metrics with their own interval to their bucket, and
+// releases the measurements of buckets that are due, or all of them if final is set.
+func (c *TimeCollatedClient) holdBack(gauges, counters []Measurement, now time.Time, final bool) ([]Measurement, []Measurement) {
+	split := func(ms []Measurement, counters bool) []Measurement {
+		kept := ms[:0]
+		for _, m := range ms {
+			i := c.matchInterval(&m)
+			if i < 0 {
+				kept = append(kept, m)
+				continue
+			}
+			b := &c.held[i]
+			if counters {
+				b.counters = append(b.counters, m)
+			} else {
+				b.gauges = append(b.gauges, m)
+			}
+		}
+		return kept
+	}
+	gauges = split(gauges, false)
+	counters = split(counters, true)
+
+	for i := range c.held {
+		if c.intervals[i].Interval <= 0 {
+			// matchInterval() skips it, so it never holds anything.
+			continue
+		}
+		b := &c.held[i]
+		if b.due.IsZero() {
+			b.due = now.Add(c.intervals[i].Interval)
+		}
+		if !final && now.Before(b.due) {
+			continue
+		}
+		gauges = append(gauges, b.gauges...)
+		counters = append(counters, b.counters...)
+		b.gauges, b.counters = nil, nil
+		for !b.due.After(now) {
+			b.due = b.due.Add(c.intervals[i].Interval)
+		}
+	}
+	return gauges, counters
+}
+
+// matchInterval returns the index of the first interval matching m, or -1.
+func (c *TimeCollatedClient) matchInterval(m *Measurement) int {
+	for i, r := range c.intervals {
+		if r.Interval > 0 && (r.Name == nil || r.Name.MatchString(m.Name)) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,39 @@
+package librato_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestZeroMetricInterval(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour,
+		librato.WithMetricIntervals(librato.MetricInterval{Name: regexp.MustCompile("^billing")}))
+	c.SetEndpoint(srv.Endpoint())
+
+	c.PushCounter("billing.requests", 1)
+	c.Close()
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() didn't return")
+	}
+
+	var counters int
+	for _, b := range srv.Batches() {
+		counters += len(b.Counters)
+	}
+	if counters != 1 {
+		t.Errorf("got %d counters, want the one of the ignored interval", counters)
+	}
+}
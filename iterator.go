@@ -0,0 +1,85 @@
+package librato
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Iterator goes through every item of a list endpoint, fetching pages as needed:
+//
+//	it := client.Services(ctx, nil)
+//	for it.Next() {
+//		fmt.Println(it.Value().Title)
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, query url.Values) ([]T, QueryInfo, error)
+	query url.Values
+
+	page   []T
+	cur    T
+	offset int
+	done   bool
+	err    error
+}
+
+func newIterator[T any](ctx context.Context, c *TimeCollatedClient, path, key string, query url.Values) *Iterator[T] {
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	return &Iterator[T]{
+		ctx:    ctx,
+		query:  query,
+		offset: offset,
+		fetch: func(ctx context.Context, query url.Values) ([]T, QueryInfo, error) {
+			return listPage[T](ctx, c, path, key, query)
+		},
+	}
+}
+
+// Next advances to the next item, returning false when there are no more items
+// or fetching a page failed.
+func (it *Iterator[T]) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.query.Set("offset", strconv.Itoa(it.offset))
+		page, info, err := it.fetch(it.ctx, it.query)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = page
+		it.offset += len(page)
+		it.done = len(page) == 0 || it.offset >= info.Found
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Services iterates over all of the account's services, starting at opts.Offset.
+func (c *TimeCollatedClient) Services(ctx context.Context, opts *ListOptions) *Iterator[Service] {
+	return newIterator[Service](ctx, c, "/services", "services", opts.values())
+}
+
+// Sources iterates over the account's sources, see ListSources().
+func (c *TimeCollatedClient) Sources(ctx context.Context, name string, opts *ListOptions) *Iterator[Source] {
+	q := opts.values()
+	if name != "" {
+		q.Set("name", name)
+	}
+	return newIterator[Source](ctx, c, "/sources", "sources", q)
+}
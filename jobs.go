@@ -0,0 +1,106 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Job states, see Job.
+const (
+	JobQueued   = "queued"
+	JobWorking  = "working"
+	JobComplete = "complete"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// Job is an asynchronous operation started by an API request, e.g. a bulk delete.
+// http://api-docs-archive.librato.com/#jobs
+type Job struct {
+	ID int64 `json:"id"`
+	// State is one of JobQueued, JobWorking, JobComplete, JobFailed or JobCanceled.
+	State string `json:"state"`
+	// Progress is the percentage of the job that's done, if Librato reports it.
+	Progress float64 `json:"progress,omitempty"`
+	// Errors describes why a job failed.
+	Errors map[string]interface{} `json:"errors,omitempty"`
+}
+
+// Done reports whether the job finished, successfully or not.
+func (j *Job) Done() bool {
+	return j.State == JobComplete || j.State == JobFailed || j.State == JobCanceled
+}
+
+// JobError is returned by WaitForJob() for jobs that failed or were canceled.
+type JobError struct {
+	Job *Job
+}
+
+func (e *JobError) Error() string {
+	if len(e.Job.Errors) > 0 {
+		return fmt.Sprintf("job %d %s: %v", e.Job.ID, e.Job.State, e.Job.Errors)
+	}
+	return fmt.Sprintf("job %d %s", e.Job.ID, e.Job.State)
+}
+
+// jobPollInterval and maxJobPollInterval bound the backoff of WaitForJob().
+const (
+	jobPollInterval    = 500 * time.Millisecond
+	maxJobPollInterval = 30 * time.Second
+)
+
+// GetJob returns the job with the given ID.
+func (c *TimeCollatedClient) GetJob(ctx context.Context, id int64) (*Job, error) {
+	j := &Job{}
+	if err := c.apiRequest(ctx, http.MethodGet, fmt.Sprintf("/jobs/%d", id), nil, nil, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// WaitForJob polls the job with the given ID, with an exponential backoff, until it's done
+// or ctx is done. It returns the finished job, and a *JobError if it didn't complete.
+func (c *TimeCollatedClient) WaitForJob(ctx context.Context, id int64) (*Job, error) {
+	interval := jobPollInterval
+	for {
+		j, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if j.Done() {
+			if j.State != JobComplete {
+				return j, &JobError{Job: j}
+			}
+			return j, nil
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return j, ctx.Err()
+		}
+		interval = min(2*interval, maxJobPollInterval)
+	}
+}
+
+// jobReference is the body of responses to requests that start a job.
+type jobReference struct {
+	ID int64 `json:"id"`
+}
+
+// apiJobRequest is like apiRequest, but waits for the job the request started, if any.
+func (c *TimeCollatedClient) apiJobRequest(ctx context.Context, method, path string, in interface{}) error {
+	var ref jobReference
+	if err := c.apiRequest(ctx, method, path, nil, in, &ref); err != nil {
+		return err
+	}
+	if ref.ID == 0 {
+		return nil
+	}
+	_, err := c.WaitForJob(ctx, ref.ID)
+	return err
+}
@@ -0,0 +1,75 @@
+package librato
+
+import (
+	"os"
+)
+
+// Environment variables read by WithKubernetesMetadata(). They're usually
+// populated from the downward API in the pod spec, e.g.
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.name
+const (
+	EnvPodName      = "POD_NAME"
+	EnvPodNamespace = "POD_NAMESPACE"
+	EnvNodeName     = "NODE_NAME"
+)
+
+// KubernetesMetadata describes the pod the process runs in.
+type KubernetesMetadata struct {
+	Pod       string
+	Namespace string
+	Node      string
+}
+
+// Tags returns the non-empty fields as tags, for use with tagged measurements.
+func (m *KubernetesMetadata) Tags() map[string]string {
+	tags := make(map[string]string, 3)
+	for k, v := range map[string]string{
+		"pod":       m.Pod,
+		"namespace": m.Namespace,
+		"node":      m.Node,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// DetectKubernetesMetadata reads pod metadata from the environment. It returns
+// nil if none of the variables are set.
+func DetectKubernetesMetadata() *KubernetesMetadata {
+	m := &KubernetesMetadata{
+		Pod:       os.Getenv(EnvPodName),
+		Namespace: os.Getenv(EnvPodNamespace),
+		Node:      os.Getenv(EnvNodeName),
+	}
+	if m.Pod == "" && m.Namespace == "" && m.Node == "" {
+		return nil
+	}
+	return m
+}
+
+// applyKubernetesMetadata uses the pod name as the source if there's none, and adds
+// the metadata to default tags in tagged mode.
+func (c *TimeCollatedClient) applyKubernetesMetadata(source string) string {
+	m := DetectKubernetesMetadata()
+	if m == nil {
+		return source
+	}
+
+	if c.tagged {
+		c.mergeDefaultTags(m.Tags())
+	}
+	if source == "" && m.Pod != "" {
+		source = sanitize(m.Pod)
+		if m.Namespace != "" {
+			source = sanitize(m.Namespace) + "." + source
+		}
+	}
+	return source
+}
@@ -0,0 +1,44 @@
+package librato
+
+import "sync"
+
+// LastValueGauge is an Aggregator that only sends the last value pushed for a gauge in
+// each flush interval, instead of every value. It suits state-style gauges sampled far
+// more often than they're reported, like a queue depth read on every request. Nothing
+// is sent for intervals without values. It's safe for concurrent use.
+//
+//	c.SetGaugeAggregator("queue.depth", &librato.LastValueGauge{})
+type LastValueGauge struct {
+	mu    sync.Mutex
+	value interface{}
+	set   bool
+}
+
+// Add replaces the value to send, see Aggregator. It can also be a map of custom
+// properties, as with PushGauge(). Properties that can't be set are ignored.
+func (g *LastValueGauge) Add(value interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value, g.set = value, true
+}
+
+// Flush returns the last value as a gauge, if there was one, see Aggregator.
+func (g *LastValueGauge) Flush() []Measurement {
+	g.mu.Lock()
+	value, set := g.value, g.set
+	g.value, g.set = nil, false
+	g.mu.Unlock()
+
+	if !set {
+		return nil
+	}
+	m := Measurement{Kind: KindGauge}
+	if props, ok := value.(map[string]interface{}); ok {
+		for k, v := range props {
+			m.Set(k, v)
+		}
+	} else {
+		m.Value = value
+	}
+	return []Measurement{m}
+}
@@ -3,16 +3,17 @@
 package librato
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,10 +30,18 @@ var (
 	ErrNoNameAnnotation = errors.New("Annotation must have name")
 )
 
-const (
-	metricsURL     = "https://metrics-api.librato.com/v1/metrics"
-	annotationsURL = "https://metrics-api.librato.com/v1/annotations"
-)
+// APIError is returned when Librato responds with an unsuccessful status code.
+// http://api-docs-archive.librato.com/#http-status-codes
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status:%d, error: %s", e.StatusCode, e.Body)
+}
+
+const defaultEndpoint = "https://metrics-api.librato.com/v1"
 
 // Annotation is a representation of librato annotation object
 // https://www.librato.com/docs/kb/visualize/annotations/
@@ -65,125 +74,559 @@ type Client interface {
 // TimeCollatedClient is Librato client with that collates metrics for `duration` and
 // sends them to Librato in a single request.
 type TimeCollatedClient struct {
-	user, token, source string
-	duration            time.Duration
-	counters            map[string]Chan
-	gauges              map[string]Chan
-	collateCounters     Chan
-	collateGauges       Chan
-	stop                chan struct{}
-	client              *http.Client
-	wg                  *sync.WaitGroup
-}
-
-func NewTimeCollatedClient(user, token, source string, duration time.Duration) *TimeCollatedClient {
-	c := &TimeCollatedClient{
-		user:            user,
-		token:           token,
-		source:          source,
-		duration:        duration,
-		counters:        make(map[string]Chan),
-		gauges:          make(map[string]Chan),
-		collateCounters: NewFlexibleChan(2 << 10),
-		collateGauges:   NewFlexibleChan(2 << 10),
-		stop:            make(chan struct{}),
-		client:          &http.Client{},
-		wg:              &sync.WaitGroup{},
+	user, token string
+	endpoint    string
+	// Settings that can be changed at runtime, see SetSource(),
+	// SetFlushInterval() and SetMaxBatchSize().
+	source     atomic.Pointer[string]
+	duration   atomic.Int64
+	maxBatch   atomic.Int64
+	reschedule chan struct{}
+	paused     atomic.Bool
+	mu         sync.Mutex
+	// closed is set under mu by Close(), after which no metrics are registered.
+	closed          bool
+	counters        map[string]*metric
+	gauges          map[string]*metric
+	collateCounters *TypedChan[Measurement]
+	collateGauges   *TypedChan[Measurement]
+	stop            chan struct{}
+	client          *http.Client
+	sink            Sink
+	wg              *sync.WaitGroup
+	clock           Clock
+	maxBufferBytes  int
+	shards          []*shard
+	idleTTL         time.Duration
+	// closing is closed when Close() is called, to stop background goroutines.
+	closing            chan struct{}
+	janitorDone        chan struct{}
+	pollers            sync.WaitGroup
+	transform          func([]Measurement) []Measurement
+	renameRules        []RenameRule
+	prefix             string
+	validation         ValidationMode
+	nonFinite          NonFinitePolicy
+	maxAge, maxFuture  time.Duration
+	timestampPolicy    TimestampPolicy
+	align              bool
+	retry              RetryPolicy
+	deadLetter         func(batch []Measurement, err error)
+	flushWorkers       int
+	batches            chan flushJob
+	encoder            Encoder
+	closeOnce          sync.Once
+	tagged             bool
+	defaultTags        map[string]string
+	sourceTemplate     string
+	noHostname         bool
+	cloudMetadata      bool
+	kubernetesMetadata bool
+	cardinality        *cardinalityGuard
+	sampling           *samplers
+	topN               []TopNRule
+	filter             atomic.Pointer[NameFilter]
+	header             http.Header
+	requestTimeout     time.Duration
+	flushDeadline      time.Duration
+	inFlight           chan struct{}
+	tracer             Tracer
+	failover           *failoverState
+	routes             []Route
+	lifecycle          *lifecycle
+	sumCounters        bool
+	period             time.Duration
+	periodSet          bool
+	dedup              bool
+	rates              map[string]*Rate
+	sets               map[string]*Set
+	repeatStates       bool
+	heartbeat          string
+	heartbeatInterval  time.Duration
+	setExactLimit      int
+	resolutions        []Resolution
+	buckets            map[resolutionKey]*resolutionBucket
+	intervals          []MetricInterval
+	ackLog             AckLog
+	summary            summary
+	// attributes are sent in the background, guarded by mu. See applyAttributes().
+	attributes     attributeUpdates
+	attributeCache AttributeCache
+	debug          io.Writer
+	debugMu        sync.Mutex
+	lastResponse   atomic.Pointer[ResponseInfo]
+	onResponse     func(ResponseInfo)
+	mpsc           bool
+	pressure       *backpressure
+	aggregators    aggregators
+	held           []heldBucket
+	jitter         time.Duration
+	jitterEvery    bool
+	onError        func(error)
+}
+
+func NewTimeCollatedClient(user, token, source string, duration time.Duration, opts ...Option) *TimeCollatedClient {
+	c := newClient(user, token, source, duration, opts)
+	c.collateGauges = NewSizedChan[Measurement](2<<10, Measurement.size, c.maxBufferBytes)
+	c.collateCounters = NewSizedChan[Measurement](2<<10, Measurement.size, c.maxBufferBytes)
+	c.startDispatchers()
+	if c.idleTTL > 0 {
+		c.janitorDone = make(chan struct{})
+		go c.expireIdle()
 	}
 	go c.work()
+	if c.heartbeat != "" {
+		c.startHeartbeat()
+	}
+	return c
+}
+
+// startHeartbeat pushes the heartbeat gauge now and then periodically, see WithHeartbeat().
+func (c *TimeCollatedClient) startHeartbeat() {
+	interval := c.heartbeatInterval
+	if interval <= 0 {
+		interval = c.flushInterval()
+	}
+	c.PushGauge(c.heartbeat, 1)
+	c.every(interval, func() {
+		c.PushGauge(c.heartbeat, 1)
+	})
+}
+
+// newClient creates a client with its options applied, without starting it.
+func newClient(user, token, source string, duration time.Duration, opts []Option) *TimeCollatedClient {
+	c := &TimeCollatedClient{
+		user:          user,
+		token:         token,
+		endpoint:      defaultEndpoint,
+		reschedule:    make(chan struct{}, 1),
+		counters:      make(map[string]*metric),
+		gauges:        make(map[string]*metric),
+		stop:          make(chan struct{}),
+		closing:       make(chan struct{}),
+		client:        &http.Client{},
+		wg:            &sync.WaitGroup{},
+		clock:         RealClock{},
+		sampling:      &samplers{rates: make(map[string]float64)},
+		setExactLimit: DefaultSetExactLimit,
+	}
+	c.source.Store(&source)
+	c.duration.Store(int64(duration))
+	c.maxBatch.Store(int64(MaxMetrics))
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.kubernetesMetadata {
+		source = c.applyKubernetesMetadata(source)
+		c.source.Store(&source)
+	}
+	if c.cloudMetadata {
+		source = c.applyCloudMetadata(source)
+		c.source.Store(&source)
+	}
+	if source == "" && !c.noHostname {
+		if hostname, err := os.Hostname(); err == nil {
+			source = sanitize(hostname)
+			c.source.Store(&source)
+		}
+	}
+	if c.sourceTemplate != "" {
+		if s, err := c.renderSource(source); err != nil {
+			c.reportError(fmt.Errorf("source template: %w", err))
+		} else {
+			c.source.Store(&s)
+		}
+	}
+	if c.lifecycle != nil {
+		c.lifecycle.started = c.clock.Now().Unix()
+	}
 	return c
 }
 
 func (c *TimeCollatedClient) work() {
-	t := time.NewTicker(c.duration)
-	gauges := []interface{}{}
-	counters := []interface{}{}
+	// The ticker is replaced after every tick, since the time to the next
+	// flush isn't always the same. See nextFlush().
+	t := c.clock.NewTicker(c.nextFlush(true))
+	gauges := NewTypedQueue[Measurement](2 << 8)
+	counters := NewTypedQueue[Measurement](2 << 8)
 	closed := 0
 	gaugeChan := c.collateGauges.Output()
 	counterChan := c.collateCounters.Output()
+	var workers sync.WaitGroup
+	if c.flushWorkers > 1 {
+		c.batches = make(chan flushJob)
+		workers.Add(c.flushWorkers)
+		for i := 0; i < c.flushWorkers; i++ {
+			go c.flushWorker(&workers)
+		}
+	}
+
+	// idle is ready (like a default case) whenever something happened since the loop
+	// last checked whether it's done or has to flush early, and nil otherwise, so the
+	// loop blocks until there is something to do.
+	ready := make(chan struct{})
+	close(ready)
+	idle := ready
 	for {
 		select {
-		case <-t.C:
-			if len(gauges) > 0 || len(counters) > 0 {
-				c.postMetric(map[string]interface{}{
-					"gauges":   gauges,
-					"counters": counters,
-				})
-				gauges, counters = nil, nil
+		case <-t.C():
+			idle = ready
+			t.Stop()
+			t = c.clock.NewTicker(c.nextFlush(false))
+			if !c.paused.Load() {
+				c.flush(gauges.Drain(), counters.Drain(), false)
 			}
+		case <-c.reschedule:
+			t.Stop()
+			t = c.clock.NewTicker(c.nextFlush(false))
 		case item, ok := <-gaugeChan:
+			idle = ready
 			if !ok {
 				closed++
 				gaugeChan = nil
 				continue
 			}
-			gauges = append(gauges, item)
+			gauges.Push(item)
 		case item, ok := <-counterChan:
+			idle = ready
 			if !ok {
 				closed++
 				counterChan = nil
 				continue
 			}
-			counters = append(counters, item)
-		default:
+			counters.Push(item)
+		case <-idle:
+			idle = nil
 			if closed == 2 {
 				t.Stop()
-				params := map[string]interface{}{}
-				if len(gauges) > 0 {
-					params["gauges"] = gauges
+				c.flush(gauges.Drain(), counters.Drain(), true)
+				if c.batches != nil {
+					close(c.batches)
+					workers.Wait()
 				}
-				if len(counters) > 0 {
-					params["counters"] = counters
-				}
-
-				if len(params) > 0 {
-					c.postMetric(params)
-				}
-
-				gauges, counters = nil, nil
 				close(c.stop)
 				return
-			} else if len(gauges)+len(counters) >= MaxMetrics {
+			} else if gauges.Len()+counters.Len() >= int(c.maxBatch.Load()) && !c.paused.Load() {
 				// Librato doesn't like requests with more than ~300 metrics
 				// so we need to flush early, without waiting for the timer.
-				params := map[string]interface{}{}
-				if len(gauges) > 0 {
-					params["gauges"] = gauges
-				}
-				if len(counters) > 0 {
-					params["counters"] = counters
-				}
+				c.flush(gauges.Drain(), counters.Drain(), false)
+			}
+		}
+	}
+}
 
-				if len(params) > 0 {
-					c.postMetric(params)
-				}
+// nextFlush returns how long to wait until the next periodic flush.
+func (c *TimeCollatedClient) nextFlush(first bool) time.Duration {
+	interval := c.flushInterval()
+	d := interval
+	if c.align && interval > 0 {
+		// Align to the Unix epoch like measure times, see prepare(). time.Truncate()
+		// aligns to the zero time, which differs for intervals that don't divide a day.
+		now := c.clock.Now().UnixNano()
+		d = time.Duration(int64(interval) - now%int64(interval))
+	}
+	if c.jitter > 0 && (first || c.jitterEvery) {
+		d += time.Duration(rand.Int63n(int64(c.jitter)))
+	}
+	return d
+}
+
+// flush sends the collated measurements, if there are any.
+func (c *TimeCollatedClient) flush(gauges, counters []Measurement, final bool) {
+	c.pressure.release(len(gauges) + len(counters))
+	if len(c.intervals) > 0 {
+		// Held back measurements aren't part of this flush, so their
+		// deliveries must not be resolved yet.
+		gauges, counters = c.holdBack(gauges, counters, c.clock.Now(), final)
+	}
 
-				gauges, counters = nil, nil
+	acks := pendingDeliveries(gauges, counters)
+	defer func() {
+		// A panic can only come from hooks, before any batch is sent.
+		if err := c.recovered(recover()); err != nil {
+			for _, d := range acks {
+				d.resolve(err)
 			}
+		}
+	}()
+
+	c.postStartedAsync()
+	if c.cardinality != nil {
+		for _, err := range c.cardinality.errors() {
+			c.reportError(err)
+		}
+	}
+
+	now := c.clock.Now()
+	for _, m := range c.aggregatorMeasurements() {
+		if m.Kind == KindCounter {
+			counters = append(counters, m)
+		} else {
+			gauges = append(gauges, m)
+		}
+	}
+	gauges = append(gauges, c.rateMeasurements(now)...)
+	gauges = append(gauges, c.setMeasurements()...)
+	gauges = c.checkTimestamps(gauges, now)
+	counters = c.checkTimestamps(counters, now)
+
+	if len(c.resolutions) > 0 {
+		for _, m := range c.aggregateResolutions(append(gauges[:len(gauges):len(gauges)], counters...), now, final) {
+			if m.Kind == KindCounter {
+				counters = append(counters, m)
+			} else {
+				gauges = append(gauges, m)
+			}
+		}
+	}
+
+	if c.sumCounters {
+		counters = sumCounters(counters)
+	}
+	if c.dedup {
+		gauges = dedup(gauges)
+		counters = dedup(counters)
+	}
+
+	for i := range c.topN {
+		gauges = c.topN[i].apply(gauges)
+		counters = c.topN[i].apply(counters)
+	}
+
+	if c.transform != nil {
+		gauges, counters = c.applyTransform(gauges, counters)
+	}
 
-			time.Sleep(1 * time.Second)
+	// Any delivery that's not part of a batch was dropped along the way.
+	if len(acks) > 0 {
+		sent := make(map[*Delivery]bool)
+		for _, d := range pendingDeliveries(gauges, counters) {
+			sent[d] = true
 		}
+		for _, d := range acks {
+			if !sent[d] {
+				d.resolve(ErrDropped)
+			}
+		}
+	}
+
+	var deadline time.Time
+	if c.flushDeadline > 0 {
+		deadline = time.Now().Add(c.flushDeadline)
+	}
+
+	var routes []routed
+	if len(c.routes) > 0 {
+		gauges, counters, routes = c.route(gauges, counters)
+	}
+	c.queueBatches(gauges, counters, nil, deadline)
+	for _, r := range routes {
+		c.queueBatches(r.gauges, r.counters, r.sink, deadline)
+	}
+}
+
+// queueBatches splits measurements into batches of at most maxBatch, gauges first,
+// and sends them to sink. A nil sink is the client's own destination.
+func (c *TimeCollatedClient) queueBatches(gauges, counters []Measurement, sink Sink, deadline time.Time) {
+	max := int(c.maxBatch.Load())
+	for len(gauges) > 0 || len(counters) > 0 {
+		batch := &Batch{ID: newBatchID()}
+		n := min(len(gauges), max)
+		batch.Gauges, gauges = gauges[:n:n], gauges[n:]
+		m := min(len(counters), max-n)
+		batch.Counters, counters = counters[:m:m], counters[m:]
+
+		job := flushJob{batch, sink, deadline}
+		if c.batches != nil {
+			c.batches <- job
+		} else {
+			c.sendBatch(job)
+		}
+	}
+}
+
+// flushJob is a batch to be sent, see queueBatches().
+type flushJob struct {
+	batch    *Batch
+	sink     Sink
+	deadline time.Time
+}
+
+// sendBatch delivers a batch and handles the outcome. A zero deadline means there's none.
+func (c *TimeCollatedClient) sendBatch(job flushJob) {
+	batch, deadline := job.batch, job.deadline
+	defer func() {
+		if err := c.recovered(recover()); err != nil {
+			resolveDeliveries(err, batch.Gauges, batch.Counters)
+		}
+	}()
+
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	ctx, span := c.startSpan(ctx, SpanFlush)
+	defer span.End()
+	span.SetAttribute(AttrGauges, len(batch.Gauges))
+	span.SetAttribute(AttrCounters, len(batch.Counters))
+
+	attempts, err := c.deliver(ctx, batch, job.sink)
+	span.SetAttribute(AttrAttempts, attempts)
+	c.summary.batch(len(batch.Gauges)+len(batch.Counters), err)
+	if err != nil {
+		span.RecordError(err)
+		c.reportError(fmt.Errorf("flush failed: %w", err))
+		if c.deadLetter != nil {
+			c.deadLetter(append(batch.Gauges[:len(batch.Gauges):len(batch.Gauges)], batch.Counters...), err)
+		}
+	}
+	resolveDeliveries(err, batch.Gauges, batch.Counters)
+}
+
+// flushWorker sends batches until the batches channel is closed.
+func (c *TimeCollatedClient) flushWorker(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range c.batches {
+		c.sendBatch(job)
 	}
 }
 
+// reportError passes an error that happened in the background to the error handler,
+// or prints it to Logger if there's none.
+func (c *TimeCollatedClient) reportError(err error) {
+	c.summary.error(err)
+	if c.onError != nil {
+		c.onError(err)
+	} else if Logger != nil {
+		Logger.Println(err)
+	}
+}
+
+// applyTransform runs the transform hook on all measurements and splits the result by kind.
+func (c *TimeCollatedClient) applyTransform(gauges, counters []Measurement) ([]Measurement, []Measurement) {
+	all := c.transform(append(gauges, counters...))
+
+	gauges, counters = nil, nil
+	for _, m := range all {
+		if m.Kind == KindCounter {
+			counters = append(counters, m)
+		} else {
+			gauges = append(gauges, m)
+		}
+	}
+	return gauges, counters
+}
+
+// SetSource changes the default source of measurements pushed from now on.
+func (c *TimeCollatedClient) SetSource(source string) {
+	c.source.Store(&source)
+}
+
+// SetFlushInterval changes how often measurements are flushed. It's safe to call at
+// any time, e.g. to reduce the reporting rate during incidents. The next flush is
+// rescheduled right away, without waiting for the current interval to finish.
+func (c *TimeCollatedClient) SetFlushInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.duration.Store(int64(d))
+	select {
+	case c.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+// SetMaxBatchSize changes the maximum number of measurements sent in a single request.
+// It defaults to MaxMetrics.
+func (c *TimeCollatedClient) SetMaxBatchSize(n int) {
+	if n > 0 {
+		c.maxBatch.Store(int64(n))
+	}
+}
+
+// Pause stops sending measurements to Librato until Resume() is called. Measurements
+// are still collated and buffered in the meantime. Close() flushes everything, even
+// if the client is paused.
+func (c *TimeCollatedClient) Pause() {
+	c.paused.Store(true)
+}
+
+// Resume undoes Pause(). Buffered measurements are sent with the next flush.
+func (c *TimeCollatedClient) Resume() {
+	c.paused.Store(false)
+}
+
+func (c *TimeCollatedClient) flushInterval() time.Duration {
+	return time.Duration(c.duration.Load())
+}
+
 // Set a custom HTTP client. Must be called before sending any metrics.
 func (c *TimeCollatedClient) SetHTTPClient(client *http.Client) {
 	c.client = client
 }
 
+// Set a custom API endpoint, e.g. a relay or a test server. Defaults to
+// https://metrics-api.librato.com/v1. Must be called before sending any metrics.
+func (c *TimeCollatedClient) SetEndpoint(endpoint string) {
+	c.endpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+// Set a custom sink that receives batches instead of the Librato API.
+// Must be called before sending any metrics.
+func (c *TimeCollatedClient) SetSink(sink Sink) {
+	c.sink = sink
+}
+
+// Close closes all metric channels and stops the client once everything is flushed.
+// Every value pushed before Close is called is part of the final flush, including
+// values of metrics registered concurrently. Values pushed after Close are dropped,
+// and metrics retrieved after Close get channels that drop them. It's safe to call more than once. Use Wait() to block until the
+// final flush is done.
 func (c *TimeCollatedClient) Close() {
-	for _, i := range c.gauges {
-		func(c Chan) {
-			c.Close()
-			c.Wait()
-		}(i)
-	}
-	for _, i := range c.counters {
-		func(c Chan) {
-			c.Close()
-			c.Wait()
-		}(i)
+	c.closeOnce.Do(c.close)
+}
+
+func (c *TimeCollatedClient) close() {
+	if c.lifecycle != nil {
+		c.postStarted()
+		c.postLifecycle("stopped", c.clock.Now().Unix())
+	}
+	// Pollers are only started under c.mu if closing isn't closed yet, see goPoller().
+	c.mu.Lock()
+	close(c.closing)
+	c.mu.Unlock()
+	c.pressure.close()
+	if c.janitorDone != nil {
+		<-c.janitorDone
+	}
+	c.pollers.Wait()
+
+	// From here on getMetric() doesn't start metric goroutines and removeMetric()
+	// doesn't close channels, so the snapshot below covers every metric.
+	c.mu.Lock()
+	c.closed = true
+	metrics := make([]*metric, 0, len(c.gauges)+len(c.counters))
+	for _, m := range c.gauges {
+		metrics = append(metrics, m)
 	}
+	for _, m := range c.counters {
+		metrics = append(metrics, m)
+	}
+	c.mu.Unlock()
+
+	for _, m := range metrics {
+		// Pushes in progress finish before the channel is closed.
+		m.retire()
+		m.ch.Wait()
+	}
+	for _, s := range c.shards {
+		s.close()
+	}
+	// Wait for every metric and dispatcher goroutine to hand its values to the
+	// collator, and only then close it. work() flushes once both are drained.
 	c.wg.Wait()
 	c.collateGauges.Close()
 	c.collateGauges.Wait()
@@ -195,24 +638,38 @@ func (c *TimeCollatedClient) Wait() {
 	<-c.stop
 }
 
-func (c *TimeCollatedClient) GetGauge(name string) Chan {
-	ch, ok := c.gauges[name]
-	if !ok {
-		ch = NewFlexibleChan(2 << 9)
-		c.gauges[name] = ch
-		go c.runMetric(name, ch, c.collateGauges)
+// Run blocks until ctx is done, then closes the client and waits for the final flush.
+// It also returns if the client is closed by other means. The client starts working as
+// soon as it's created, so Run is only a convenience to tie its lifetime to a context,
+// e.g. in an errgroup.Group:
+//
+//	g.Go(func() error { return client.Run(ctx) })
+func (c *TimeCollatedClient) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+	case <-c.stop:
 	}
-	return ch
+	c.Close()
+	c.Wait()
+	return nil
+}
+
+func (c *TimeCollatedClient) GetGauge(name string) Chan {
+	return c.getMetric(c.gauges, KindGauge, name, c.collateGauges)
 }
 
 func (c *TimeCollatedClient) GetCounter(name string) Chan {
-	ch, ok := c.counters[name]
-	if !ok {
-		ch = NewFlexibleChan(2 << 9)
-		c.counters[name] = ch
-		go c.runMetric(name, ch, c.collateCounters)
+	return c.getMetric(c.counters, KindCounter, name, c.collateCounters)
+}
+
+func (c *TimeCollatedClient) newMetricChan() Chan {
+	if c.maxBufferBytes > 0 {
+		return NewSizedChan[interface{}](2<<9, estimateSize, c.maxBufferBytes)
+	}
+	if c.mpsc {
+		return NewFlexibleMPSCChan(2 << 9)
 	}
-	return ch
+	return NewFlexibleChan(2 << 9)
 }
 
 // PostAnnotation sends annotation to librato API right away
@@ -223,83 +680,144 @@ func (c *TimeCollatedClient) PostAnnotation(body *Annotation, name string) error
 		return ErrNoNameAnnotation
 	}
 
-	b, err := json.Marshal(body)
+	buf, err := c.encode(body)
 	if nil != err {
 		return err
 	}
+	defer buf.Close()
 
-	return c.makeRequest(bytes.NewBuffer(b), fmt.Sprintf("%s/%s", annotationsURL, name))
+	return c.makeRequest(context.Background(), buf, fmt.Sprintf("%s/annotations/%s", c.endpoint, name))
 }
 
-func (c *TimeCollatedClient) postMetric(body map[string]interface{}) error {
-	b, err := json.Marshal(body)
-	if nil != err {
-		return err
+// send delivers a batch to sink, or the configured sink, or to Librato if there is none.
+func (c *TimeCollatedClient) send(ctx context.Context, batch *Batch, sink Sink) error {
+	if sink != nil {
+		return sink.Send(ctx, batch)
 	}
-
-	return c.makeRequest(bytes.NewBuffer(b), metricsURL)
+	if c.sink != nil {
+		return c.sink.Send(ctx, batch)
+	}
+	return c.postBatch(ctx, batch)
 }
 
-func (c *TimeCollatedClient) makeRequest(data *bytes.Buffer, url string) error {
-	req, err := http.NewRequest(http.MethodPost, url, data)
-	if nil != err {
-		return err
+// postBatch posts a batch to the Librato API. Batches recorded in the ack log
+// are skipped, and accepted ones are added to it.
+func (c *TimeCollatedClient) postBatch(ctx context.Context, batch *Batch) error {
+	if c.ackLog != nil && batch.ID != "" && c.ackLog.Acked(batch.ID) {
+		return nil
+	}
+
+	if c.validation == ValidateStrict {
+		if err := c.strictBatch(batch); err != nil {
+			return err
+		}
+	}
+
+	ctx = withPayloadCount(ctx, len(batch.Gauges)+len(batch.Counters))
+	var err error
+	if c.tagged {
+		err = c.postPayload(ctx, c.newTaggedPayload(batch), "/measurements")
+	} else {
+		err = c.postPayload(ctx, &Batch{Gauges: batch.Gauges, Counters: batch.Counters}, "/metrics")
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.SetBasicAuth(c.user, c.token)
-	res, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
 
-	// Do not discard response body in case of Librato errors
-	// http://api-docs-archive.librato.com/#http-status-codes
-	if res.StatusCode <= 204 {
-		io.Copy(ioutil.Discard, res.Body)
+	if c.ackLog != nil && batch.ID != "" {
+		if err := c.ackLog.Ack(batch.ID); err != nil {
+			c.reportError(fmt.Errorf("ack log: %w", err))
+		}
 	}
+	return nil
+}
 
-	// http://api-docs-archive.librato.com/#http-status-codes
-	if res.StatusCode > 204 && Logger != nil {
-		b, _ := ioutil.ReadAll(res.Body)
-		Logger.Printf("status:%d, error: %s\n", res.StatusCode, string(b))
+func (c *TimeCollatedClient) postPayload(ctx context.Context, payload interface{}, path string) error {
+	buf, err := c.encode(payload)
+	if err != nil {
+		return err
 	}
+	defer buf.Close()
+	return c.post(ctx, buf, path)
+}
 
-	return nil
+// makeRequest posts data to url. If data is an io.Closer, it's closed once the request is done.
+func (c *TimeCollatedClient) makeRequest(ctx context.Context, data io.Reader, url string) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodPost, url, data)
+	if nil != err {
+		return err
+	}
+	return c.do(req, nil)
 }
 
-func (c *TimeCollatedClient) runMetric(name string, ch Chan, collate Chan) {
-	c.wg.Add(1)
-	for {
-		select {
-		case item, ok := <-ch.Output():
-			if !ok {
-				c.wg.Done()
-				return
-			}
+func (c *TimeCollatedClient) runMetric(kind MetricKind, name string, m *metric, collate *TypedChan[Measurement]) {
+	defer c.wg.Done()
+	// Pop skips the Output() goroutine of MPSC channels, see WithMPSCChannels().
+	if p, ok := m.ch.(interface{ Pop() (interface{}, bool) }); ok {
+		for item, ok := p.Pop(); ok; item, ok = p.Pop() {
+			c.forward(kind, name, m, item, collate)
+		}
+		return
+	}
+	for item := range m.ch.Output() {
+		c.forward(kind, name, m, item, collate)
+	}
+}
 
-			body := map[string]interface{}{
-				"name":         name,
-				"measure_time": time.Now().Unix(),
-			}
-			if c.source != "" {
-				body["source"] = c.source
-			}
+// forward turns an item pushed to a metric channel into a measurement and sends it to
+// the collator. A panic only drops the item, the metric keeps working.
+func (c *TimeCollatedClient) forward(kind MetricKind, name string, m *metric, item interface{}, collate *TypedChan[Measurement]) {
+	item, ack := unwrapAck(item)
+	defer func() {
+		if c.recovered(recover()) != nil {
+			c.pressure.release(1)
+			ack.resolve(ErrDropped)
+		}
+	}()
+
+	now := c.clock.Now()
+	m.touch(now)
+	m.lastPush.Store(now.UnixNano())
+	if c.aggregate(kind, name, item) {
+		ack.resolve(nil)
+		return
+	}
+	ms := c.newMeasurement(kind, name, item)
+	ms.ack = ack
+	if c.prepare(&ms) {
+		collate.Push(ms)
+	} else {
+		c.pressure.release(1)
+		ack.resolve(ErrDropped)
+	}
+}
 
-			switch typedItem := item.(type) {
-			case map[string]interface{}:
-				for k, v := range typedItem {
-					body[k] = v
-				}
-			default:
-				body["value"] = item
-			}
+// newMeasurement builds a measurement from a pushed item, which is either
+// a value or a map of custom properties.
+func (c *TimeCollatedClient) newMeasurement(kind MetricKind, name string, item interface{}) Measurement {
+	m := Measurement{
+		Kind:        kind,
+		Name:        name,
+		Source:      *c.source.Load(),
+		MeasureTime: c.clock.Now().Unix(),
+	}
 
-			if _, present := body["measure_time"]; !present {
-				body["measure_time"] = time.Now().Unix()
+	switch typedItem := item.(type) {
+	case map[string]interface{}:
+		for k, v := range typedItem {
+			if err := m.Set(k, v); err != nil {
+				c.reportError(&ValidationError{Name: name, Source: m.Source, Reason: err.Error() + ", ignored"})
 			}
-
-			collate.Input() <- body
 		}
+	default:
+		m.Value = item
+	}
+
+	if m.MeasureTime == 0 {
+		m.MeasureTime = c.clock.Now().Unix()
 	}
+	return m
 }
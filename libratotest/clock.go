@@ -0,0 +1,86 @@
+package libratotest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dcelasun/librato"
+)
+
+// Clock is a librato.Clock that only moves when Advance is called,
+// so flushes can be triggered deterministically.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+var _ librato.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock set to the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTicker(d time.Duration) librato.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &ticker{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		d:     d,
+		next:  c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing any tickers that are due.
+// Like time.Ticker, ticks are dropped if the previous one wasn't received yet.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+type ticker struct {
+	clock *Clock
+	c     chan time.Time
+	d     time.Duration
+	next  time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
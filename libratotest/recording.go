@@ -0,0 +1,182 @@
+// package libratotest provides utilities for testing code that uses the librato package.
+package libratotest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dcelasun/librato"
+)
+
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Measurement is a single value pushed to a RecordingClient.
+type Measurement struct {
+	// Kind is either Gauge or Counter.
+	Kind string
+	Name string
+	// Value is the item as it was pushed, e.g. a number or a map of custom properties.
+	Value interface{}
+	// MeasureTime is the measure_time pushed along with the value, e.g. by
+	// TimeCollatedClient.PushAt(). It's zero if there was none.
+	MeasureTime time.Time
+}
+
+// PostedAnnotation is an annotation posted to a RecordingClient.
+type PostedAnnotation struct {
+	Name       string
+	Annotation librato.Annotation
+}
+
+// RecordingClient implements librato.Client by keeping everything
+// in memory instead of sending it to Librato, so tests can assert
+// on emitted metrics.
+//
+// Measurements are recorded asynchronously, just like the real client.
+// Call Close() before inspecting them to make sure none are in flight.
+type RecordingClient struct {
+	mu           sync.Mutex
+	gauges       map[string]librato.Chan
+	counters     map[string]librato.Chan
+	measurements []Measurement
+	annotations  []PostedAnnotation
+	wg           sync.WaitGroup
+}
+
+var _ librato.Client = (*RecordingClient)(nil)
+
+func NewRecordingClient() *RecordingClient {
+	return &RecordingClient{
+		gauges:   make(map[string]librato.Chan),
+		counters: make(map[string]librato.Chan),
+	}
+}
+
+func (c *RecordingClient) GetGauge(name string) librato.Chan {
+	return c.get(c.gauges, Gauge, name)
+}
+
+func (c *RecordingClient) GetCounter(name string) librato.Chan {
+	return c.get(c.counters, Counter, name)
+}
+
+func (c *RecordingClient) PostAnnotation(body *librato.Annotation, name string) error {
+	if name == "" {
+		return librato.ErrNoNameAnnotation
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.annotations = append(c.annotations, PostedAnnotation{Name: name, Annotation: *body})
+	return nil
+}
+
+// Close closes all metric channels and waits until every pushed value is recorded.
+func (c *RecordingClient) Close() {
+	c.mu.Lock()
+	chans := make([]librato.Chan, 0, len(c.gauges)+len(c.counters))
+	for _, ch := range c.gauges {
+		chans = append(chans, ch)
+	}
+	for _, ch := range c.counters {
+		chans = append(chans, ch)
+	}
+	c.mu.Unlock()
+
+	for _, ch := range chans {
+		ch.Close()
+	}
+	c.wg.Wait()
+}
+
+func (c *RecordingClient) Wait() {
+	c.wg.Wait()
+}
+
+// Measurements returns all recorded measurements in the order they were received.
+func (c *RecordingClient) Measurements() []Measurement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Measurement(nil), c.measurements...)
+}
+
+// MeasurementsFor returns the recorded measurements with the given name,
+// regardless of their kind.
+func (c *RecordingClient) MeasurementsFor(name string) []Measurement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ms []Measurement
+	for _, m := range c.measurements {
+		if m.Name == name {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// Values returns the recorded values of the given kind and name.
+func (c *RecordingClient) Values(kind, name string) []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var vs []interface{}
+	for _, m := range c.measurements {
+		if m.Kind == kind && m.Name == name {
+			vs = append(vs, m.Value)
+		}
+	}
+	return vs
+}
+
+// Annotations returns all posted annotations.
+func (c *RecordingClient) Annotations() []PostedAnnotation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]PostedAnnotation(nil), c.annotations...)
+}
+
+// Reset discards everything recorded so far. Metric channels stay open.
+func (c *RecordingClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.measurements = nil
+	c.annotations = nil
+}
+
+func (c *RecordingClient) get(chans map[string]librato.Chan, kind, name string) librato.Chan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := chans[name]
+	if !ok {
+		ch = librato.NewFlexibleChan(2 << 9)
+		chans[name] = ch
+		c.wg.Add(1)
+		go c.record(kind, name, ch)
+	}
+	return ch
+}
+
+func (c *RecordingClient) record(kind, name string, ch librato.Chan) {
+	defer c.wg.Done()
+	for item := range ch.Output() {
+		c.mu.Lock()
+		c.measurements = append(c.measurements, Measurement{Kind: kind, Name: name, Value: item, MeasureTime: measureTime(item)})
+		c.mu.Unlock()
+	}
+}
+
+// measureTime returns the measure_time of a pushed map of custom properties, if any.
+func measureTime(item interface{}) time.Time {
+	props, ok := item.(map[string]interface{})
+	if !ok {
+		return time.Time{}
+	}
+	var m librato.Measurement
+	if err := m.Set("measure_time", props["measure_time"]); err != nil {
+		return time.Time{}
+	}
+	return m.Time()
+}
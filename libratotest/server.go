@@ -0,0 +1,208 @@
+package libratotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/dcelasun/librato"
+)
+
+// Server is a fake Librato API for integration tests. It implements
+// metric submission and annotations, captures every accepted payload,
+// and can simulate failures such as 413 and 429 responses.
+//
+// Point a client at it with client.SetEndpoint(server.Endpoint()).
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	batches     []librato.Batch
+	annotations []PostedAnnotation
+	requests    int
+	failures    []int
+	maxPayload  int64
+	user, token string
+}
+
+// NewServer starts a new fake server. Call Close() when done.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint returns the API endpoint to configure clients with.
+func (s *Server) Endpoint() string {
+	return s.URL + "/v1"
+}
+
+// RequireAuth makes the server reject requests without the given basic auth credentials.
+func (s *Server) RequireAuth(user, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user, s.token = user, token
+}
+
+// SetMaxPayload makes the server respond with 413 - Request Entity Too Large
+// to requests with bodies larger than n bytes. 0 means no limit.
+func (s *Server) SetMaxPayload(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPayload = n
+}
+
+// FailNext makes the next n requests fail with the given status code.
+// 429 responses include a Retry-After header.
+func (s *Server) FailNext(status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Batches returns all accepted metric batches, in the order they were received.
+// Values are json.Number, so integers can be told apart from floats.
+func (s *Server) Batches() []librato.Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]librato.Batch(nil), s.batches...)
+}
+
+// Annotations returns all accepted annotations, in the order they were received.
+func (s *Server) Annotations() []PostedAnnotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PostedAnnotation(nil), s.annotations...)
+}
+
+// Requests returns the total number of requests received, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Reset discards all captured payloads and pending failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = nil
+	s.annotations = nil
+	s.requests = 0
+	s.failures = nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if s.user != "" || s.token != "" {
+		user, token, ok := r.BasicAuth()
+		if !ok || user != s.user || token != s.token {
+			writeError(w, http.StatusUnauthorized, "Authorization Required")
+			return
+		}
+	}
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.maxPayload > 0 && int64(len(body)) > s.maxPayload {
+		writeError(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/metrics":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"query":{"found":0,"total":0,"offset":0,"length":0},"metrics":[]}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/metrics":
+		var batch librato.Batch
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.batches = append(s.batches, batch)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/measurements":
+		batch, err := decodeTagged(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.batches = append(s.batches, batch)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/annotations/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/annotations/")
+		var a librato.Annotation
+		if err := json.Unmarshal(body, &a); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.annotations = append(s.annotations, PostedAnnotation{Name: name, Annotation: a})
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, len(s.annotations))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// decodeTagged converts a tagged measurements payload to a batch of gauges,
+// merging the top level tags into every measurement.
+func decodeTagged(body []byte) (librato.Batch, error) {
+	var payload struct {
+		Tags         map[string]string `json:"tags"`
+		Measurements []struct {
+			librato.Measurement
+			Time int64 `json:"time"`
+		} `json:"measurements"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return librato.Batch{}, err
+	}
+
+	var batch librato.Batch
+	for _, m := range payload.Measurements {
+		tags := make(map[string]string, len(payload.Tags)+len(m.Tags))
+		for k, v := range payload.Tags {
+			tags[k] = v
+		}
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		m.Measurement.Tags = tags
+		m.Measurement.MeasureTime = m.Time
+		batch.Gauges = append(batch.Gauges, m.Measurement)
+	}
+	return batch, nil
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": map[string][]string{"request": {msg}},
+	})
+}
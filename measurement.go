@@ -0,0 +1,241 @@
+package librato
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MetricKind is the kind of metric a measurement belongs to.
+type MetricKind int
+
+const (
+	KindGauge MetricKind = iota
+	KindCounter
+)
+
+func (k MetricKind) String() string {
+	switch k {
+	case KindGauge:
+		return "gauge"
+	case KindCounter:
+		return "counter"
+	default:
+		return "unknown"
+	}
+}
+
+// Measurement is a single gauge or counter value, as submitted to Librato.
+// http://api-docs-archive.librato.com/#create-a-measurement
+type Measurement struct {
+	// Kind is not submitted, it decides which list of the Batch the measurement goes to.
+	Kind        MetricKind  `json:"-"`
+	Name        string      `json:"name"`
+	Value       interface{} `json:"value,omitempty"`
+	Source      string      `json:"source,omitempty"`
+	MeasureTime int64       `json:"measure_time,omitempty"`
+	// Tags are only sent in tagged mode, see WithDefaultTags().
+	Tags map[string]string `json:"tags,omitempty"`
+	// Period is the expected interval between measurements of the metric, in seconds.
+	// Librato persists it as a metric attribute, see WithPeriod().
+	Period int64 `json:"period,omitempty"`
+
+	// Gauges can be submitted as pre-aggregated samples instead of a single value,
+	// in which case Count and Sum are required.
+	Count      *int64   `json:"count,omitempty"`
+	Sum        *float64 `json:"sum,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	SumSquares *float64 `json:"sum_squares,omitempty"`
+
+	// ack is resolved once the measurement is sent, see PushGaugeAck().
+	ack *Delivery
+}
+
+// Set sets a measurement property by its Librato name, e.g. "value" or "sum_squares".
+// The measure_time can be given as a time.Time or in Unix seconds, and the period
+// as a time.Duration or in seconds.
+// It returns an error for unknown properties and values of the wrong type,
+// which would otherwise be ignored by Librato.
+func (m *Measurement) Set(key string, value interface{}) error {
+	switch key {
+	case "name", "source":
+		s, ok := value.(string)
+		if !ok {
+			break
+		}
+		if key == "name" {
+			m.Name = s
+		} else {
+			m.Source = s
+		}
+		return nil
+	case "value":
+		m.Value = value
+		return nil
+	case "tags":
+		switch tags := value.(type) {
+		case map[string]string:
+			m.Tags = tags
+			return nil
+		case map[string]interface{}:
+			m.Tags = make(map[string]string, len(tags))
+			for k, v := range tags {
+				m.Tags[k] = fmt.Sprint(v)
+			}
+			return nil
+		}
+	case "measure_time":
+		if t, ok := value.(time.Time); ok {
+			m.SetTime(t)
+			return nil
+		}
+		if t, ok := toInt64(value); ok {
+			m.MeasureTime = t
+			return nil
+		}
+	case "period":
+		if d, ok := value.(time.Duration); ok {
+			m.Period = int64(d / time.Second)
+			return nil
+		}
+		if p, ok := toInt64(value); ok {
+			m.Period = p
+			return nil
+		}
+	case "count":
+		if n, ok := toInt64(value); ok {
+			m.Count = &n
+			return nil
+		}
+	case "sum", "min", "max", "sum_squares":
+		f, ok := toFloat64(value)
+		if !ok {
+			break
+		}
+		switch key {
+		case "sum":
+			m.Sum = &f
+		case "min":
+			m.Min = &f
+		case "max":
+			m.Max = &f
+		case "sum_squares":
+			m.SumSquares = &f
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown property %q", key)
+	}
+	return fmt.Errorf("invalid value %v (%T) for property %q", value, value, key)
+}
+
+// SetTime sets the measure_time of the measurement.
+func (m *Measurement) SetTime(t time.Time) {
+	m.MeasureTime = t.Unix()
+}
+
+// Time returns the measure_time of the measurement, or the zero time if it's not set.
+func (m *Measurement) Time() time.Time {
+	if m.MeasureTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(m.MeasureTime, 0)
+}
+
+// toInt64 returns v as an int64 if it's of any integer type, a float or a json.Number.
+// Floats are truncated. Unsigned values that don't fit are rejected.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := n.Float64(); err == nil {
+			return int64(f), true
+		}
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return uintToInt64(uint64(n))
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return uintToInt64(n)
+	case uintptr:
+		return uintToInt64(uint64(n))
+	case float32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func uintToInt64(n uint64) (int64, bool) {
+	if n > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// toFloat64 returns v as a float64 if it's of any number type or a json.Number.
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uintptr:
+		return float64(n), true
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// size estimates the memory used by the measurement in bytes.
+func (m Measurement) size() int {
+	return 128 + len(m.Name) + len(m.Source) + estimateSize(m.Value)
+}
+
+// estimateSize roughly estimates the memory used by a pushed item in bytes.
+func estimateSize(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return 16 + len(v)
+	case map[string]interface{}:
+		n := 48
+		for k, item := range v {
+			n += 16 + len(k) + estimateSize(item)
+		}
+		return n
+	case Measurement:
+		return v.size()
+	default:
+		return 16
+	}
+}
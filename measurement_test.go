@@ -0,0 +1,68 @@
+package librato
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNumberConversions(t *testing.T) {
+	for _, tc := range []struct {
+		v        interface{}
+		i        int64
+		intOK    bool
+		f        float64
+		integral bool
+	}{
+		{int(-3), -3, true, -3, true},
+		{int8(-8), -8, true, -8, true},
+		{int16(-16), -16, true, -16, true},
+		{int32(-32), -32, true, -32, true},
+		{int64(-64), -64, true, -64, true},
+		{uint(3), 3, true, 3, true},
+		{uint8(8), 8, true, 8, true},
+		{uint16(16), 16, true, 16, true},
+		{uint32(32), 32, true, 32, true},
+		{uint64(64), 64, true, 64, true},
+		{uintptr(7), 7, true, 7, true},
+		{uint64(math.MaxUint64), 0, false, math.MaxUint64, true},
+		{float32(1.5), 1, true, 1.5, false},
+		{float64(1e6), 1e6, true, 1e6, true},
+		{json.Number("12"), 12, true, 12, true},
+		{json.Number("1.5"), 1, true, 1.5, false},
+	} {
+		i, ok := toInt64(tc.v)
+		if ok != tc.intOK || i != tc.i {
+			t.Errorf("toInt64(%T %v) = %d, %t, want %d, %t", tc.v, tc.v, i, ok, tc.i, tc.intOK)
+		}
+		if f, ok := toFloat64(tc.v); !ok || f != tc.f {
+			t.Errorf("toFloat64(%T %v) = %v, %t, want %v", tc.v, tc.v, f, ok, tc.f)
+		}
+		if !numeric(tc.v) {
+			t.Errorf("numeric(%T %v) = false", tc.v, tc.v)
+		}
+		if integral(tc.v) != tc.integral {
+			t.Errorf("integral(%T %v) = %t, want %t", tc.v, tc.v, !tc.integral, tc.integral)
+		}
+	}
+
+	for _, v := range []interface{}{"1", true, nil, complex(1, 0)} {
+		if _, ok := toFloat64(v); ok {
+			t.Errorf("toFloat64(%T %v) accepted", v, v)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	for v, want := range map[float64]string{
+		1e6:    "1000000",
+		-42:    "-42",
+		0.25:   "0.25",
+		1.5e-7: "1.5e-07",
+		1e21:   "1e+21",
+	} {
+		if got := formatValue(v); got != want {
+			t.Errorf("formatValue(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
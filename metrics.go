@@ -0,0 +1,148 @@
+package librato
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Metric is a metric's metadata.
+// http://api-docs-archive.librato.com/#metrics
+type Metric struct {
+	Name string `json:"name"`
+	// Type is "gauge", "counter" or "composite".
+	Type        string `json:"type,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Period is the expected interval between measurements, in seconds.
+	Period     int64             `json:"period,omitempty"`
+	Attributes *MetricAttributes `json:"attributes,omitempty"`
+}
+
+// MetricAttributes control how a metric is displayed in charts.
+// http://api-docs-archive.librato.com/#metric-attributes
+type MetricAttributes struct {
+	// DisplayUnitsLong is e.g. "Milliseconds", and DisplayUnitsShort "ms".
+	DisplayUnitsLong  string `json:"display_units_long,omitempty"`
+	DisplayUnitsShort string `json:"display_units_short,omitempty"`
+	// DisplayMin and DisplayMax set the default range of the Y axis.
+	DisplayMin     *float64 `json:"display_min,omitempty"`
+	DisplayMax     *float64 `json:"display_max,omitempty"`
+	DisplayStacked bool     `json:"display_stacked,omitempty"`
+	Color          string   `json:"color,omitempty"`
+	// SummarizeFunction is e.g. "average", "sum", "min" or "max".
+	SummarizeFunction string `json:"summarize_function,omitempty"`
+	// Aggregate enables server side aggregation of measurements within the period.
+	Aggregate bool `json:"aggregate,omitempty"`
+}
+
+// GetMetric returns the metadata of the named metric.
+func (c *TimeCollatedClient) GetMetric(ctx context.Context, name string) (*Metric, error) {
+	m := &Metric{}
+	if err := c.apiRequest(ctx, http.MethodGet, "/metrics/"+url.PathEscape(name), nil, nil, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateMetric updates the metadata of the metric named m.Name, creating the metric if
+// it doesn't exist, in which case m.Type is required. Empty fields are left unchanged.
+func (c *TimeCollatedClient) UpdateMetric(ctx context.Context, m *Metric) error {
+	return c.apiRequest(ctx, http.MethodPut, "/metrics/"+url.PathEscape(m.Name), nil, m, nil)
+}
+
+// ListMetrics returns a page of the account's metrics. If name isn't empty, only
+// metrics whose names contain it are returned.
+func (c *TimeCollatedClient) ListMetrics(ctx context.Context, name string, opts *ListOptions) ([]Metric, QueryInfo, error) {
+	q := opts.values()
+	if name != "" {
+		q.Set("name", name)
+	}
+	return listPage[Metric](ctx, c, "/metrics", "metrics", q)
+}
+
+// Metrics iterates over the account's metrics, see ListMetrics().
+func (c *TimeCollatedClient) Metrics(ctx context.Context, name string, opts *ListOptions) *Iterator[Metric] {
+	q := opts.values()
+	if name != "" {
+		q.Set("name", name)
+	}
+	return newIterator[Metric](ctx, c, "/metrics", "metrics", q)
+}
+
+// DeleteMetrics deletes the named metrics and all of their measurements in one request.
+// If Librato deletes them asynchronously, it waits for the job, see WaitForJob().
+func (c *TimeCollatedClient) DeleteMetrics(ctx context.Context, names []string) error {
+	body := map[string][]string{"names": names}
+	return c.apiJobRequest(ctx, http.MethodDelete, "/metrics", body)
+}
+
+// DeleteOptions controls DeleteMetricsMatching().
+type DeleteOptions struct {
+	// DryRun only lists the matching metrics, without deleting them.
+	DryRun bool
+	// Confirm is called with the names of all matching metrics before any of them is
+	// deleted. Nothing is deleted unless it returns true. A nil Confirm deletes them.
+	Confirm func(names []string) bool
+	// BatchSize is the number of metrics deleted per request, 100 by default.
+	BatchSize int
+}
+
+// DeleteMetricsMatching deletes every metric whose name matches pattern, e.g. metrics
+// left behind by experiments. Patterns are globs as in path.Match (e.g. "exp.*.latency"),
+// or regular expressions if they're enclosed in slashes, like in NewNameFilter().
+// It returns the names of the matching metrics, which were deleted unless it's a dry run,
+// the deletion wasn't confirmed or it failed. After an error, some batches may have been
+// deleted already.
+func (c *TimeCollatedClient) DeleteMetricsMatching(ctx context.Context, pattern string, opts DeleteOptions) ([]string, error) {
+	matchers, err := compilePatterns([]string{pattern})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	it := c.Metrics(ctx, literalPart(pattern), nil)
+	for it.Next() {
+		if name := it.Value().Name; matchers[0](name) {
+			names = append(names, name)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 || opts.DryRun || (opts.Confirm != nil && !opts.Confirm(names)) {
+		return names, nil
+	}
+
+	size := opts.BatchSize
+	if size <= 0 {
+		size = 100
+	}
+	for batch := names; len(batch) > 0; {
+		n := min(len(batch), size)
+		if err := c.DeleteMetrics(ctx, batch[:n]); err != nil {
+			return names, err
+		}
+		batch = batch[n:]
+	}
+	return names, nil
+}
+
+// literalPart returns the longest part of a glob pattern without wildcards, to narrow
+// down the metrics listed by the API. It's empty for regular expressions.
+func literalPart(pattern string) string {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return ""
+	}
+	var longest string
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return strings.ContainsRune(`*?[]\`, r)
+	}) {
+		if len(part) > len(longest) {
+			longest = part
+		}
+	}
+	return longest
+}
@@ -0,0 +1,73 @@
+package librato
+
+import (
+	"math"
+	"sync"
+)
+
+// MinMax is an Aggregator that tracks the minimum and maximum of the values of a gauge in
+// each flush interval, and reports them as the gauges "<name>.min" and "<name>.max". It's
+// an alternative to pre-aggregated gauges for accounts that don't use their min and max
+// fields. Nothing is reported for intervals without values. It's safe for concurrent use.
+// Use GetMinMax(), or set it as the aggregator of a gauge:
+//
+//	c.SetGaugeAggregator("latency", &librato.MinMax{})
+type MinMax struct {
+	mu       sync.Mutex
+	min, max float64
+	n        int
+}
+
+// Record adds a value to the current interval. NaN is ignored.
+func (m *MinMax) Record(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.n == 0 || v < m.min {
+		m.min = v
+	}
+	if m.n == 0 || v > m.max {
+		m.max = v
+	}
+	m.n++
+}
+
+// Add records a pushed value, see Aggregator. Values that aren't numbers are ignored.
+func (m *MinMax) Add(value interface{}) {
+	if f, ok := toFloat64(value); ok {
+		m.Record(f)
+	}
+}
+
+// Flush returns the minimum and maximum of the interval as gauges, see Aggregator.
+func (m *MinMax) Flush() []Measurement {
+	m.mu.Lock()
+	min, max, n := m.min, m.max, m.n
+	m.n = 0
+	m.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+	return []Measurement{
+		{Kind: KindGauge, Name: ".min", Value: min},
+		{Kind: KindGauge, Name: ".max", Value: max},
+	}
+}
+
+// GetMinMax returns the MinMax of the named gauge, setting it as the gauge's aggregator
+// if needed, so that values pushed for the gauge are recorded too. Another aggregator
+// of the gauge is replaced. See SetGaugeAggregator().
+func (c *TimeCollatedClient) GetMinMax(name string) *MinMax {
+	return aggregatorOf(&c.aggregators, aggregatorKey{KindGauge, name}, func() *MinMax {
+		return &MinMax{}
+	})
+}
+
+// RecordMinMax records a value for the named MinMax, see GetMinMax().
+func (c *TimeCollatedClient) RecordMinMax(name string, v float64) {
+	c.GetMinMax(name).Record(v)
+}
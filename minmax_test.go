@@ -0,0 +1,43 @@
+package librato_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestMinMax(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour)
+	c.SetEndpoint(srv.Endpoint())
+
+	c.RecordMinMax("latency", 3)
+	c.RecordMinMax("latency", 1)
+	// Pushed values are recorded by the gauge's MinMax instead of being sent.
+	c.PushGauge("latency", 7)
+	c.GetMinMax("empty")
+	c.SetGaugeAggregator("size", &librato.MinMax{})
+	c.PushGauge("size", 5)
+	c.Close()
+	c.Wait()
+
+	got := make(map[string]interface{})
+	for _, b := range srv.Batches() {
+		for _, g := range b.Gauges {
+			got[g.Name] = g.Value
+		}
+	}
+	want := map[string]string{"latency.min": "1", "latency.max": "7", "size.min": "5", "size.max": "5"}
+	if len(got) != len(want) {
+		t.Errorf("got gauges %v, want %v", got, want)
+	}
+	for name, v := range want {
+		if fmt.Sprint(got[name]) != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package librato
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// FlexibleMPSCChan is an MPSCChan of arbitrary items, implementing Chan.
+type FlexibleMPSCChan = MPSCChan[interface{}]
+
+// MPSCChan is an unbounded multi-producer, single-consumer channel for high throughput
+// workloads. Push() appends to a mutex protected ring buffer and the consumer takes
+// everything buffered at once, so unlike TypedChan there's no worker goroutine
+// between producers and the consumer.
+//
+// Input() and Output() are supported for compatibility with Chan, but each starts a
+// goroutine forwarding items, so Push() and Pop() should be preferred.
+// Only one goroutine may consume items at a time.
+type MPSCChan[T any] struct {
+	mu     sync.Mutex
+	buf    *TypedQueue[T]
+	closed bool
+	// wake has a buffer of 1, it's signaled when items are pushed or the channel closes.
+	wake chan struct{}
+	// quit is closed once the channel is closed and drained.
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// local holds items taken from buf, only accessed by the consumer.
+	// held is its length, for Len().
+	local []T
+	held  atomic.Int64
+
+	// tees receive a copy of every item pushed, see Tee(). They're replaced under mu.
+	tees []*MPSCChan[T]
+
+	ms         int
+	inOnce     sync.Once
+	in         chan T
+	outOnce    sync.Once
+	out        chan T
+	closeOnce  sync.Once
+	forwarding sync.WaitGroup
+}
+
+func NewFlexibleMPSCChan(ms int) *FlexibleMPSCChan {
+	return NewMPSCChan[interface{}](ms)
+}
+
+// NewMPSCChan returns an MPSCChan with a minimum buffer size of ms, which must be
+// a power of two.
+func NewMPSCChan[T any](ms int) *MPSCChan[T] {
+	return &MPSCChan[T]{
+		buf:  NewTypedQueue[T](ms),
+		wake: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+		ms:   ms,
+	}
+}
+
+// Push appends an item. It never blocks for longer than it takes to take the lock.
+// Like TypedChan.Push(), it must not be called after Close().
+func (c *MPSCChan[T]) Push(item T) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		panic("librato: push to closed MPSCChan")
+	}
+	c.buf.Push(item)
+	// Tees are pushed to under mu, so that Close() can't close them in the meantime.
+	for _, t := range c.tees {
+		t.Push(item)
+	}
+	c.mu.Unlock()
+	c.signal()
+}
+
+// Tee returns a channel that receives a copy of every item pushed to c from now on, see
+// TypedChan.Tee(). The tee has the same minimum buffer size as c, and is closed once c
+// is closed. Since it's unbounded, a tee that isn't read grows without limit.
+func (c *MPSCChan[T]) Tee() *MPSCChan[T] {
+	t := NewMPSCChan[T](c.ms)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		t.Close()
+		return t
+	}
+	tees := make([]*MPSCChan[T], len(c.tees), len(c.tees)+1)
+	copy(tees, c.tees)
+	c.tees = append(tees, t)
+	return t
+}
+
+// TryPush is Push, since an MPSCChan always accepts items. It reports true.
+func (c *MPSCChan[T]) TryPush(item T) bool {
+	c.Push(item)
+	return true
+}
+
+// PushContext is Push, since an MPSCChan always accepts items. It only fails if ctx is done.
+func (c *MPSCChan[T]) PushContext(ctx context.Context, item T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Push(item)
+	return nil
+}
+
+func (c *MPSCChan[T]) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pop reads an item, blocking until one is available.
+// The second return value is false if the channel is closed and drained.
+func (c *MPSCChan[T]) Pop() (T, bool) {
+	item, ok, _ := c.PopContext(context.Background())
+	return item, ok
+}
+
+// PopContext reads an item, blocking until one is available or ctx is done.
+// ok is false if the channel is closed and drained, err is ctx.Err() if ctx is done first.
+func (c *MPSCChan[T]) PopContext(ctx context.Context) (item T, ok bool, err error) {
+	for len(c.local) == 0 {
+		c.mu.Lock()
+		c.local = c.buf.Drain()
+		closed := c.closed
+		c.mu.Unlock()
+		if len(c.local) > 0 {
+			break
+		}
+		if closed {
+			c.quitOnce.Do(func() { close(c.quit) })
+			return item, false, nil
+		}
+
+		select {
+		case <-c.wake:
+		case <-ctx.Done():
+			return item, false, ctx.Err()
+		}
+	}
+
+	item = c.local[0]
+	var zero T
+	c.local[0] = zero
+	c.local = c.local[1:]
+	c.held.Store(int64(len(c.local)))
+	return item, true, nil
+}
+
+// Close closes the channel. Buffered items can still be read.
+func (c *MPSCChan[T]) Close() {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		in := c.in
+		c.mu.Unlock()
+		if in != nil {
+			// Items sent to Input() before Close are pushed first.
+			close(in)
+			c.forwarding.Wait()
+		}
+
+		c.mu.Lock()
+		c.closed = true
+		tees := c.tees
+		c.mu.Unlock()
+		c.signal()
+		for _, t := range tees {
+			t.Close()
+		}
+	})
+}
+
+// Wait blocks until the channel is closed and all of its items were read.
+func (c *MPSCChan[T]) Wait() {
+	<-c.quit
+}
+
+// Input returns a channel that forwards items to Push(), starting a goroutine the
+// first time it's called.
+func (c *MPSCChan[T]) Input() chan<- T {
+	c.inOnce.Do(func() {
+		in := make(chan T, c.ms)
+		c.forwarding.Add(1)
+		go func() {
+			defer c.forwarding.Done()
+			for item := range in {
+				c.Push(item)
+			}
+		}()
+		c.mu.Lock()
+		c.in = in
+		c.mu.Unlock()
+	})
+	return c.in
+}
+
+// Output returns a channel that's fed with Pop(), starting a goroutine the first time
+// it's called. It's closed once the channel is closed and drained. Pop() must not be
+// used along with it.
+func (c *MPSCChan[T]) Output() <-chan T {
+	c.outOnce.Do(func() {
+		out := make(chan T, c.ms)
+		go func() {
+			defer close(out)
+			for {
+				item, ok := c.Pop()
+				if !ok {
+					return
+				}
+				out <- item
+			}
+		}()
+		c.mu.Lock()
+		c.out = out
+		c.mu.Unlock()
+	})
+	return c.out
+}
+
+// Len returns the number of buffered items. Since the channel is in constant use,
+// the result is only a snapshot.
+func (c *MPSCChan[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Length() + int(c.held.Load()) + len(c.in) + len(c.out)
+}
+
+// Cap returns the current buffer capacity.
+func (c *MPSCChan[T]) Cap() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Cap() + cap(c.in) + cap(c.out)
+}
@@ -0,0 +1,119 @@
+package librato
+
+import (
+	"errors"
+	"sync"
+)
+
+// MultiClient forwards every metric and annotation to several clients, e.g. two
+// accounts during a migration, or Librato and a client with a FileSink.
+type MultiClient struct {
+	clients []Client
+
+	mu       sync.Mutex
+	gauges   map[string]Chan
+	counters map[string]Chan
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+var _ Client = (*MultiClient)(nil)
+
+// NewMultiClient returns a client that forwards to all of the given clients.
+// It takes ownership of them, closing them when it's closed.
+func NewMultiClient(clients ...Client) *MultiClient {
+	return &MultiClient{
+		clients:  clients,
+		gauges:   make(map[string]Chan),
+		counters: make(map[string]Chan),
+	}
+}
+
+// GetGauge returns a channel whose values are pushed to the named gauge of every client.
+func (mc *MultiClient) GetGauge(name string) Chan {
+	return mc.get(mc.gauges, name, Client.GetGauge)
+}
+
+// GetCounter returns a channel whose values are pushed to the named counter of every client.
+func (mc *MultiClient) GetCounter(name string) Chan {
+	return mc.get(mc.counters, name, Client.GetCounter)
+}
+
+func (mc *MultiClient) get(chans map[string]Chan, name string, get func(Client, string) Chan) Chan {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.closed {
+		// The channels and clients are closed, see TimeCollatedClient.Close().
+		return discardChan{}
+	}
+	ch, ok := chans[name]
+	if !ok {
+		ch = NewFlexibleChan(2 << 9)
+		chans[name] = ch
+		mc.wg.Add(1)
+		go mc.forward(ch, name, get)
+	}
+	return ch
+}
+
+// forward pushes every item of ch to the matching metric of each client, in order.
+// Items are shared between clients, so maps must not be modified after being pushed.
+func (mc *MultiClient) forward(ch Chan, name string, get func(Client, string) Chan) {
+	defer mc.wg.Done()
+
+	outs := make([]Chan, len(mc.clients))
+	for i, c := range mc.clients {
+		outs[i] = get(c, name)
+	}
+	for item := range ch.Output() {
+		for _, out := range outs {
+			out.Input() <- item
+		}
+	}
+}
+
+// PostAnnotation posts the annotation with every client, returning all their errors.
+func (mc *MultiClient) PostAnnotation(body *Annotation, name string) error {
+	var errs []error
+	for _, c := range mc.clients {
+		if err := c.PostAnnotation(body, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close forwards every value pushed so far, then closes all clients. Values pushed
+// afterwards are dropped.
+func (mc *MultiClient) Close() {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return
+	}
+	mc.closed = true
+	var chans []Chan
+	for _, ch := range mc.gauges {
+		chans = append(chans, ch)
+	}
+	for _, ch := range mc.counters {
+		chans = append(chans, ch)
+	}
+	mc.mu.Unlock()
+
+	for _, ch := range chans {
+		ch.Close()
+	}
+	mc.wg.Wait()
+	for _, c := range mc.clients {
+		c.Close()
+	}
+}
+
+// Wait blocks until every client is done.
+func (mc *MultiClient) Wait() {
+	for _, c := range mc.clients {
+		c.Wait()
+	}
+}
@@ -0,0 +1,31 @@
+package librato_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestMultiClientAfterClose(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	var clients []librato.Client
+	for i := 0; i < 2; i++ {
+		c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour)
+		c.SetEndpoint(srv.Endpoint())
+		clients = append(clients, c)
+	}
+	mc := librato.NewMultiClient(clients...)
+	mc.GetGauge("gauge").Input() <- 1
+	mc.Close()
+	mc.Wait()
+
+	// Neither an existing nor a new metric may panic or reach the closed clients.
+	mc.GetGauge("gauge").Input() <- 2
+	mc.GetCounter("counter").Input() <- 1
+	if n := len(srv.Batches()); n != 2 {
+		t.Errorf("got %d batches, want one per client", n)
+	}
+}
@@ -0,0 +1,484 @@
+package librato
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Option configures optional behaviour of a TimeCollatedClient.
+type Option func(*TimeCollatedClient)
+
+// WithClock sets the Clock used for timestamps and flush timers. Defaults to RealClock.
+func WithClock(clock Clock) Option {
+	return func(c *TimeCollatedClient) {
+		c.clock = clock
+	}
+}
+
+// WithMaxBufferBytes limits the estimated memory buffered by each metric channel,
+// as well as the internal gauge and counter channels, to roughly n bytes.
+// Once a channel reaches its limit, pushes to it block until it drains.
+func WithMaxBufferBytes(n int) Option {
+	return func(c *TimeCollatedClient) {
+		c.maxBufferBytes = n
+	}
+}
+
+// WithDispatcher enables dispatcher mode for PushGauge() and PushCounter(). Instead of
+// going through a channel and a goroutine per metric name, pushed values are appended to
+// one of n lock protected buffers (sharded by name), each drained by its own worker.
+// This keeps the number of goroutines fixed regardless of how many metric names are used.
+// PushInt(), PushFloat() and PushCounterInt() don't allocate in dispatcher mode, as their
+// values are only boxed once a worker drains them. Any other push, and any push without
+// a dispatcher, allocates to box its value. See BenchmarkPush.
+func WithDispatcher(n int) Option {
+	return func(c *TimeCollatedClient) {
+		if n > 0 {
+			c.shards = make([]*shard, n)
+		}
+	}
+}
+
+// WithIdleTTL removes gauges and counters that haven't been used for ttl, as if
+// RemoveGauge() or RemoveCounter() was called. Channels returned by GetGauge() and
+// GetCounter() must not be kept around with this option; get them again for each push.
+func WithIdleTTL(ttl time.Duration) Option {
+	return func(c *TimeCollatedClient) {
+		c.idleTTL = ttl
+	}
+}
+
+// WithTransform sets a hook that's called with all gauges and counters right before
+// each flush. Whatever it returns is sent instead, so it can drop, rewrite or add
+// measurements. The Kind of each measurement decides whether it's sent as a gauge or
+// a counter. The hook may be called with no measurements.
+func WithTransform(fn func([]Measurement) []Measurement) Option {
+	return func(c *TimeCollatedClient) {
+		c.transform = fn
+	}
+}
+
+// WithRenameRules sets rules to normalize metric names and sources before they are
+// collated. Every matching rule is applied in order, each one seeing the result of
+// the previous ones.
+func WithRenameRules(rules ...RenameRule) Option {
+	return func(c *TimeCollatedClient) {
+		c.renameRules = rules
+	}
+}
+
+// WithPrefix prepends prefix to the name of every gauge and counter, e.g. "myservice.".
+// It's applied after rename rules, so those should match unprefixed names.
+func WithPrefix(prefix string) Option {
+	return func(c *TimeCollatedClient) {
+		c.prefix = prefix
+	}
+}
+
+// WithValidation sets how measurements with invalid names or sources are handled.
+// Defaults to ValidateSanitize.
+func WithValidation(mode ValidationMode) Option {
+	return func(c *TimeCollatedClient) {
+		c.validation = mode
+	}
+}
+
+// WithErrorHandler sets a function to call with errors that happen in the background,
+// e.g. failed flushes or dropped measurements. It must be safe for concurrent use.
+// By default errors are printed to Logger.
+func WithErrorHandler(fn func(error)) Option {
+	return func(c *TimeCollatedClient) {
+		c.onError = fn
+	}
+}
+
+// WithNonFinite sets how NaN and infinite values are handled. Defaults to NonFiniteDrop.
+func WithNonFinite(policy NonFinitePolicy) Option {
+	return func(c *TimeCollatedClient) {
+		c.nonFinite = policy
+	}
+}
+
+// WithTimestampWindow makes the client check the measure_time of measurements right before
+// each flush. Measurements older than maxAge or more than maxFuture ahead of the flush time
+// are re-stamped or dropped according to policy, and reported as a *ValidationError.
+// A zero duration disables the respective check. Both are disabled by default.
+func WithTimestampWindow(maxAge, maxFuture time.Duration, policy TimestampPolicy) Option {
+	return func(c *TimeCollatedClient) {
+		c.maxAge = maxAge
+		c.maxFuture = maxFuture
+		c.timestampPolicy = policy
+	}
+}
+
+// WithAlignedFlush aligns flushes to multiples of the flush interval (in UTC), e.g. every
+// :00 and :30 seconds for 30 second intervals, and floors the measure_time of measurements
+// to the start of their interval. This makes collated data from many hosts line up cleanly.
+func WithAlignedFlush() Option {
+	return func(c *TimeCollatedClient) {
+		c.align = true
+	}
+}
+
+// WithJitter delays the first flush by a random duration of up to max, so that instances
+// started at the same time don't flush in lockstep. If every is true, each following flush
+// is delayed by a new random duration as well, instead of keeping the initial offset.
+func WithJitter(max time.Duration, every bool) Option {
+	return func(c *TimeCollatedClient) {
+		c.jitter = max
+		c.jitterEvery = every
+	}
+}
+
+// WithRetry sets how failed batches are retried. By default they aren't.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *TimeCollatedClient) {
+		c.retry = policy
+	}
+}
+
+// WithDeadLetter sets a function to call with the measurements of batches that
+// couldn't be delivered, after all retries failed. It can be used to persist or
+// re-route them. The Kind of each measurement tells gauges and counters apart.
+func WithDeadLetter(fn func(batch []Measurement, err error)) Option {
+	return func(c *TimeCollatedClient) {
+		c.deadLetter = fn
+	}
+}
+
+// WithFlushWorkers sends batches from n goroutines, so that a slow response doesn't
+// delay the following flushes. At most n requests are in flight at any time, and
+// the sink (if any) is called concurrently. Defaults to 1.
+func WithFlushWorkers(n int) Option {
+	return func(c *TimeCollatedClient) {
+		c.flushWorkers = n
+	}
+}
+
+// WithEncoder sets a custom JSON encoder for request payloads, e.g. one based on
+// json-iterator for clients that submit lots of measurements. Defaults to encoding/json.
+func WithEncoder(enc Encoder) Option {
+	return func(c *TimeCollatedClient) {
+		c.encoder = enc
+	}
+}
+
+// WithDefaultTags switches the client to Librato's tagged measurements API and attaches
+// tags to every measurement, e.g. environment, region or service name. Tags of individual
+// measurements (set with a "tags" property) take precedence. In this mode counters are
+// sent as gauges and sources as a "source" tag.
+func WithDefaultTags(tags map[string]string) Option {
+	return func(c *TimeCollatedClient) {
+		c.tagged = true
+		c.defaultTags = tags
+	}
+}
+
+// WithSourceTemplate sets the default source from a text/template, executed once when
+// the client is created. The template can use {{.Hostname}}, {{.Source}} (the source
+// passed to the constructor), {{.Tags}} (see WithDefaultTags()) and {{env "NAME"}},
+// e.g. "{{.Hostname}}-{{env \"ENVIRONMENT\"}}". Errors are reported to the error handler
+// and leave the source unchanged.
+func WithSourceTemplate(tmpl string) Option {
+	return func(c *TimeCollatedClient) {
+		c.sourceTemplate = tmpl
+	}
+}
+
+// WithoutHostnameSource disables the default source. Without it, clients created with an
+// empty source use the (sanitized) hostname instead.
+func WithoutHostnameSource() Option {
+	return func(c *TimeCollatedClient) {
+		c.noHostname = true
+	}
+}
+
+// WithCloudMetadata detects whether the process runs on EC2 or GCE when the client is
+// created. NewTimeCollatedClient() and NewSimpleClient() block while the metadata
+// services are probed, which can take up to a second. If it does, the instance ID is used as the
+// default source (unless one was given) and, in tagged mode, the instance ID, zone and
+// instance type are added to the default tags. See DetectCloudMetadata().
+func WithCloudMetadata() Option {
+	return func(c *TimeCollatedClient) {
+		c.cloudMetadata = true
+	}
+}
+
+// WithKubernetesMetadata reads the pod name, namespace and node from the environment
+// (see EnvPodName and friends). The "namespace.pod" pair is used as the default source
+// unless one was given and, in tagged mode, the pod, namespace and node are added to the
+// default tags. It takes precedence over WithCloudMetadata() for the source.
+func WithKubernetesMetadata() Option {
+	return func(c *TimeCollatedClient) {
+		c.kubernetesMetadata = true
+	}
+}
+
+// WithCardinalityLimit caps the number of distinct metric names and, in tagged mode,
+// series the client submits. Measurements of new series beyond the limit are dropped
+// or collapsed depending on the policy, and a *CardinalityError is reported with each
+// flush in which that happened.
+func WithCardinalityLimit(limit CardinalityLimit) Option {
+	return func(c *TimeCollatedClient) {
+		c.cardinality = newCardinalityGuard(limit)
+	}
+}
+
+// WithSampleRates keeps only a random fraction of the measurements of the given metrics,
+// e.g. 0.1 to keep 10% of them. Rates are keyed by the name the metric is pushed with.
+// Pre-aggregated gauges that are kept have their count, sum and sum of squares divided
+// by the rate, so totals and averages stay accurate. So do counter values with
+// WithCounterSums(), where they are increments. Otherwise counters are running totals,
+// and they are sent as they are, like plain gauge values. Rates can be changed at
+// runtime with SetSampleRate().
+func WithSampleRates(rates map[string]float64) Option {
+	return func(c *TimeCollatedClient) {
+		for name, rate := range rates {
+			c.sampling.set(name, rate)
+		}
+	}
+}
+
+// WithTopN sets rules that limit the number of sources (or tag values) high-cardinality
+// metrics are submitted with in each flush, rolling up the rest. Rules are applied in
+// order, before the transform hook. See TopNRule.
+func WithTopN(rules ...TopNRule) Option {
+	return func(c *TimeCollatedClient) {
+		c.topN = rules
+	}
+}
+
+// WithNameFilter drops measurements of metrics whose names aren't allowed by f. Names
+// are matched after rename rules and the prefix are applied. The filter can be replaced
+// at runtime with SetNameFilter().
+func WithNameFilter(f *NameFilter) Option {
+	return func(c *TimeCollatedClient) {
+		c.filter.Store(f)
+	}
+}
+
+// WithHeader adds a header to every request made to the API. Headers set this way replace
+// the defaults, so it can be used to change the User-Agent (see DefaultUserAgent). Calling
+// it multiple times with the same key adds multiple values.
+func WithHeader(key, value string) Option {
+	return func(c *TimeCollatedClient) {
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		c.header.Add(key, value)
+	}
+}
+
+// WithRequestTimeout limits how long each request to the API may take, including reading
+// the response. The default http.Client has no timeout, so a hung connection could stall
+// flushing forever.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *TimeCollatedClient) {
+		c.requestTimeout = d
+	}
+}
+
+// WithFlushDeadline limits how long sending the batches of a single flush may take,
+// including retries. Batches that aren't sent by then fail with context.DeadlineExceeded.
+// The deadline is also passed to custom sinks.
+func WithFlushDeadline(d time.Duration) Option {
+	return func(c *TimeCollatedClient) {
+		c.flushDeadline = d
+	}
+}
+
+// WithMaxInFlight limits the number of concurrent requests to the API, including
+// flushes, annotations and management calls. Requests over the limit wait for a slot.
+func WithMaxInFlight(n int) Option {
+	return func(c *TimeCollatedClient) {
+		if n > 0 {
+			c.inFlight = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithTransport replaces the HTTP client with one using a transport tuned by cfg, so
+// that connections are reused between flushes. A client set with SetHTTPClient() replaces
+// it in turn.
+func WithTransport(cfg TransportConfig) Option {
+	return func(c *TimeCollatedClient) {
+		c.client = &http.Client{Transport: cfg.newTransport()}
+	}
+}
+
+// WithTracer traces each batch sent by a flush (with its size and number of attempts)
+// and each request made to the API (with its method, URL and status code).
+func WithTracer(t Tracer) Option {
+	return func(c *TimeCollatedClient) {
+		c.tracer = t
+	}
+}
+
+// WithFailover sends batches to a secondary endpoint while the primary one keeps
+// failing with network errors, 429 or 5xx responses, switching back once it recovers.
+// Switching to the secondary endpoint is reported to the error handler, and switching
+// back to Failover.OnRecover and the debug writer (see WithDebug()). Annotations and
+// other API calls always go to the primary endpoint.
+func WithFailover(f Failover) Option {
+	return func(c *TimeCollatedClient) {
+		c.failover = newFailoverState(f)
+	}
+}
+
+// WithRoutes sets routes that override the source or destination of matching metrics,
+// e.g. to send billing metrics to a restricted account. Each measurement follows the
+// first route that matches it. Routes are applied at flush time, after the transform hook.
+func WithRoutes(routes ...Route) Option {
+	return func(c *TimeCollatedClient) {
+		c.routes = routes
+	}
+}
+
+// WithLifecycleAnnotations posts "Process started" and "Process stopped" annotations to
+// the given stream, with the hostname, PID and version in their description, so restarts
+// show up on charts. The start annotation is posted with the first flush (or on Close(),
+// whichever comes first) and the stop annotation by Close(). The version may be empty.
+func WithLifecycleAnnotations(stream, version string) Option {
+	return func(c *TimeCollatedClient) {
+		c.lifecycle = &lifecycle{stream: stream, version: version}
+	}
+}
+
+// WithCounterSums treats counter values as increments, like statsd counters, summing
+// those of the same name and source (and tags) within a flush interval into a single
+// measurement. Otherwise every push is sent as a separate measurement.
+func WithCounterSums() Option {
+	return func(c *TimeCollatedClient) {
+		c.sumCounters = true
+	}
+}
+
+// WithPeriod sets the period of gauges that don't have one, so that Librato aggregates
+// and displays collated data correctly. A period of 0 uses the flush interval. The period
+// of individual measurements can be set by pushing a map with a "period" key.
+func WithPeriod(period time.Duration) Option {
+	return func(c *TimeCollatedClient) {
+		c.period = period
+		c.periodSet = true
+	}
+}
+
+// WithDedup drops measurements that are repeated within a flush with the same name,
+// source (and tags) and measure_time, keeping the last one. Deliveries of dropped
+// measurements fail with ErrDropped. Counters are summed first if WithCounterSums()
+// is also used.
+func WithDedup() Option {
+	return func(c *TimeCollatedClient) {
+		c.dedup = true
+	}
+}
+
+// WithSetExactLimit sets the number of distinct values a Set counts exactly in each
+// interval, before it switches to a HyperLogLog estimate to bound its memory use.
+// Defaults to DefaultSetExactLimit.
+func WithSetExactLimit(n int) Option {
+	return func(c *TimeCollatedClient) {
+		c.setExactLimit = n
+	}
+}
+
+// WithResolutions additionally reports matching metrics aggregated over longer
+// intervals, under different names. Gauges are reported as pre-aggregated samples and
+// counters with their last value in each interval. Aggregates are sent with the first
+// flush at least one flush interval after their interval is over, or on Close().
+func WithResolutions(resolutions ...Resolution) Option {
+	return func(c *TimeCollatedClient) {
+		c.resolutions = resolutions
+		c.buckets = make(map[resolutionKey]*resolutionBucket)
+	}
+}
+
+// WithMetricIntervals sends matching metrics at their own, longer intervals. Their
+// measurements are collated separately and sent along with the first flush after their
+// interval is over. Each metric follows the first interval that matches it.
+func WithMetricIntervals(intervals ...MetricInterval) Option {
+	return func(c *TimeCollatedClient) {
+		c.intervals = intervals
+		c.held = make([]heldBucket, len(intervals))
+	}
+}
+
+// WithAckLog records the ID of every batch accepted by the Librato API in log, and
+// skips batches already recorded there. Together with a FileSink, which keeps batch
+// IDs, it prevents double counting when spooled batches are replayed.
+func WithAckLog(log AckLog) Option {
+	return func(c *TimeCollatedClient) {
+		c.ackLog = log
+	}
+}
+
+// WithAttributeCache keeps track of the metric attributes set with GetGaugeWithAttributes()
+// and GetCounterWithAttributes() in cache, so that attributes which were already applied
+// aren't sent again when the process restarts. See NewFileAttributeCache().
+func WithAttributeCache(cache AttributeCache) Option {
+	return func(c *TimeCollatedClient) {
+		c.attributeCache = cache
+	}
+}
+
+// WithDebug logs every API request to w: its method, URL, number of measurements and
+// size, the response status, and the response body of failed requests. Credentials are
+// always redacted, so the output can be shared, e.g. in support tickets to Librato.
+func WithDebug(w io.Writer) Option {
+	return func(c *TimeCollatedClient) {
+		c.debug = w
+	}
+}
+
+// WithResponseHandler calls fn with the info of every response to an API request, e.g. to
+// export rate limits or log request IDs. It's called synchronously, so it must be fast.
+// See also LastResponseInfo().
+func WithResponseHandler(fn func(ResponseInfo)) Option {
+	return func(c *TimeCollatedClient) {
+		c.onResponse = fn
+	}
+}
+
+// WithMPSCChannels uses an MPSCChan instead of a FlexibleChan for every gauge and counter.
+// Values pushed with PushGauge() and PushCounter() then skip a goroutine hop and contend
+// on a mutex instead of a channel, which makes a push two to three times cheaper (see
+// BenchmarkFlexibleChan and BenchmarkMPSCChan). A FlexibleChan already takes a few million
+// pushes per second per metric, so this only pays off for metrics pushed in hot loops.
+// Channels are unbounded, so WithMaxBufferBytes() takes precedence over this option.
+func WithMPSCChannels() Option {
+	return func(c *TimeCollatedClient) {
+		c.mpsc = true
+	}
+}
+
+// WithBackpressure makes PushGauge(), PushCounter() and the other Push methods block
+// once n measurements have been pushed but not yet flushed, until a flush makes room.
+// Use PushGaugeContext() or PushCounterContext() to give up waiting. Pushes block for
+// as long as the client is paused. Once Close() is called, blocked pushes are dropped.
+func WithBackpressure(n int) Option {
+	return func(c *TimeCollatedClient) {
+		if n > 0 {
+			c.pressure = newBackpressure(n)
+		}
+	}
+}
+
+// WithRepeatedStates sends every state set with SetState() at each flush, even if it
+// wasn't set again since the previous one, until it's removed with ClearState().
+// Alerts on absent data then only fire when the process stops reporting altogether.
+func WithRepeatedStates() Option {
+	return func(c *TimeCollatedClient) {
+		c.repeatStates = true
+	}
+}
+
+// WithHeartbeat pushes a gauge of 1 with the given name right away and then every
+// interval, for as long as the client is running. An alert on absent data for it then
+// detects processes that died or hung. An interval of 0 uses the flush interval.
+func WithHeartbeat(name string, interval time.Duration) Option {
+	return func(c *TimeCollatedClient) {
+		c.heartbeat = name
+		c.heartbeatInterval = interval
+	}
+}
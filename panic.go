@@ -0,0 +1,35 @@
+package librato
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is reported when a background goroutine recovers from a panic,
+// e.g. one raised by a user provided hook. See WithErrorHandler().
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("recovered from panic: %v\n%s", e.Value, e.Stack)
+}
+
+// recovered turns the result of recover() into a *PanicError and reports it.
+// It returns nil if there was no panic.
+func (c *TimeCollatedClient) recovered(r interface{}) error {
+	if r == nil {
+		return nil
+	}
+
+	err := &PanicError{Value: r, Stack: debug.Stack()}
+	defer func() {
+		// The error handler itself panicked, so there's nowhere else to report it.
+		if r := recover(); r != nil && Logger != nil {
+			Logger.Println(err)
+		}
+	}()
+	c.reportError(err)
+	return err
+}
@@ -0,0 +1,95 @@
+package librato
+
+import (
+	"regexp"
+	"time"
+)
+
+// RenameRule rewrites the name and/or source of measurements matching regular expressions.
+// See WithRenameRules().
+type RenameRule struct {
+	// Name and Source are matched against the metric name and source. A nil
+	// expression matches anything, but at least one of them should be set.
+	Name   *regexp.Regexp
+	Source *regexp.Regexp
+	// NameTemplate and SourceTemplate replace the name and source of matching measurements.
+	// They are expanded with the submatches of Name and Source respectively, using the same
+	// syntax as regexp.Regexp.Expand (e.g. $1 or ${service}). Empty templates keep the original.
+	NameTemplate   string
+	SourceTemplate string
+}
+
+// apply renames m if it matches the rule and reports whether it did.
+func (r *RenameRule) apply(m *Measurement) bool {
+	var nameMatch, sourceMatch []int
+	if r.Name != nil {
+		if nameMatch = r.Name.FindStringSubmatchIndex(m.Name); nameMatch == nil {
+			return false
+		}
+	}
+	if r.Source != nil {
+		if sourceMatch = r.Source.FindStringSubmatchIndex(m.Source); sourceMatch == nil {
+			return false
+		}
+	}
+
+	name, source := m.Name, m.Source
+	if r.NameTemplate != "" {
+		m.Name = expand(r.Name, r.NameTemplate, name, nameMatch)
+	}
+	if r.SourceTemplate != "" {
+		m.Source = expand(r.Source, r.SourceTemplate, source, sourceMatch)
+	}
+	return true
+}
+
+func expand(re *regexp.Regexp, template, src string, match []int) string {
+	if re == nil {
+		return template
+	}
+	return string(re.ExpandString(nil, template, src, match))
+}
+
+// prepare runs a new measurement through the pipeline before it's collated.
+// It returns false if the measurement should be dropped.
+func (c *TimeCollatedClient) prepare(m *Measurement) bool {
+	// Sampling comes first, as it's the cheapest way to drop a measurement.
+	if !c.sampling.sample(m, c.sumCounters) {
+		return false
+	}
+	for i := range c.renameRules {
+		c.renameRules[i].apply(m)
+	}
+	m.Name = c.prefix + m.Name
+	if c.periodSet && m.Kind == KindGauge && m.Period == 0 {
+		m.Period = int64(c.gaugePeriod() / time.Second)
+	}
+	if f := c.filter.Load(); f != nil && !f.Allowed(m.Name) {
+		return false
+	}
+	if period := int64(c.flushInterval() / time.Second); c.align && period > 0 {
+		m.MeasureTime -= m.MeasureTime % period
+	}
+
+	if err := c.validate(m); err != nil {
+		c.reportError(err)
+		return false
+	}
+
+	keep, err := c.checkFinite(m)
+	if err != nil {
+		c.reportError(err)
+	}
+	if keep && c.cardinality != nil {
+		keep = c.cardinality.check(m, c.tagged)
+	}
+	return keep
+}
+
+// gaugePeriod returns the period set with WithPeriod(), or the flush interval.
+func (c *TimeCollatedClient) gaugePeriod() time.Duration {
+	if c.period > 0 {
+		return c.period
+	}
+	return c.flushInterval()
+}
@@ -0,0 +1,100 @@
+package librato
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PoolStats is a snapshot of a connection pool. Fields a pool doesn't track are left zero
+// and aren't reported. Counts of events (hits, misses and so on) are cumulative.
+type PoolStats struct {
+	// Hits and Misses count requests for a connection that did (and didn't) find an idle one.
+	Hits, Misses uint64
+	// Timeouts counts requests for a connection that timed out.
+	Timeouts uint64
+	// WaitCount and WaitDuration count requests that had to wait for a connection,
+	// and the total time they waited.
+	WaitCount    uint64
+	WaitDuration time.Duration
+	// Closed counts connections closed by the pool, e.g. for being idle or stale.
+	Closed uint64
+
+	// Total, Idle and InUse are the current number of connections, and Max the limit.
+	Total, Idle, InUse, Max int
+}
+
+// PoolStatser is a connection pool that can report its stats.
+//
+// A go-redis client can be adapted with a PoolStatsFunc:
+//
+//	librato.PoolStatsFunc(func() librato.PoolStats {
+//		s := rdb.PoolStats()
+//		return librato.PoolStats{
+//			Hits:     uint64(s.Hits),
+//			Misses:   uint64(s.Misses),
+//			Timeouts: uint64(s.Timeouts),
+//			Closed:   uint64(s.StaleConns),
+//			Total:    int(s.TotalConns),
+//			Idle:     int(s.IdleConns),
+//			InUse:    int(s.TotalConns - s.IdleConns),
+//		}
+//	})
+type PoolStatser interface {
+	PoolStats() PoolStats
+}
+
+// PoolStatsFunc is a function implementing PoolStatser.
+type PoolStatsFunc func() PoolStats
+
+func (f PoolStatsFunc) PoolStats() PoolStats {
+	return f()
+}
+
+// SQLPoolStats adapts the stats of a database/sql connection pool.
+func SQLPoolStats(db *sql.DB) PoolStatser {
+	return PoolStatsFunc(func() PoolStats {
+		s := db.Stats()
+		return PoolStats{
+			WaitCount:    uint64(s.WaitCount),
+			WaitDuration: s.WaitDuration,
+			Closed:       uint64(s.MaxIdleClosed + s.MaxIdleTimeClosed + s.MaxLifetimeClosed),
+			Total:        s.OpenConnections,
+			Idle:         s.Idle,
+			InUse:        s.InUse,
+			Max:          s.MaxOpenConnections,
+		}
+	})
+}
+
+// ReportPoolStats reports the stats of a connection pool every interval, until stop is
+// called or the client is closed. Cumulative counts are pushed as counters named
+// <name>.hits, .misses, .timeouts, .waits, .wait_ms (the total wait time in whole
+// milliseconds) and .closed, and connection counts as gauges named <name>.conns.total,
+// .idle, .in_use and .max. Since the counters are running totals, they must not be
+// summed with WithCounterSums().
+func (c *TimeCollatedClient) ReportPoolStats(name string, pool PoolStatser, interval time.Duration) (stop func()) {
+	return c.every(interval, func() {
+		s := pool.PoolStats()
+		for suffix, v := range map[string]uint64{
+			".hits":     s.Hits,
+			".misses":   s.Misses,
+			".timeouts": s.Timeouts,
+			".waits":    s.WaitCount,
+			".closed":   s.Closed,
+		} {
+			if v > 0 {
+				c.PushCounter(name+suffix, int64(v))
+			}
+		}
+		if ms := s.WaitDuration.Milliseconds(); ms > 0 {
+			c.PushCounter(name+".wait_ms", ms)
+		}
+
+		c.PushGauge(name+".conns.total", s.Total)
+		c.PushGauge(name+".conns.idle", s.Idle)
+		c.PushGauge(name+".conns.in_use", s.InUse)
+		if s.Max > 0 {
+			c.PushGauge(name+".conns.max", s.Max)
+		}
+	})
+}
@@ -1,10 +1,13 @@
 package librato
 
-// Queue implements a simple FIFO queue using a ring buffer.
+// Queue is a TypedQueue of arbitrary items, kept for compatibility.
+type Queue = TypedQueue[interface{}]
+
+// TypedQueue implements a simple FIFO queue using a ring buffer.
 // It has a minimum buffer size of "ms", which must be a power of two.
-type Queue struct {
+type TypedQueue[T any] struct {
 	// Buffer to store queued items in.
-	items []interface{}
+	items []T
 	// Positions of the start and end items,
 	// number of items in the Queue,
 	// minimum size of the Queue.
@@ -12,26 +15,37 @@ type Queue struct {
 	// Both start and end will wrap around to the
 	// beginning of the buffer as needed.
 	start, end, count, ms int
+	// Optional estimator of item sizes in bytes,
+	// and the total size of items in the Queue.
+	sizer func(T) int
+	size  int
 }
 
 func NewQueue(minBufferSize int) *Queue {
+	return NewTypedQueue[interface{}](minBufferSize)
+}
+
+func NewTypedQueue[T any](minBufferSize int) *TypedQueue[T] {
 	if minBufferSize == 0 || minBufferSize&-minBufferSize != minBufferSize {
 		panic("Queue size must be a power of two.")
 	}
 
-	return &Queue{
-		items: make([]interface{}, minBufferSize),
+	return &TypedQueue[T]{
+		items: make([]T, minBufferSize),
 		ms:    minBufferSize,
 	}
 }
 
-func (q *Queue) Push(item interface{}) {
+func (q *TypedQueue[T]) Push(item T) {
 	if q.count == len(q.items) {
 		// Queue is full, grow it.
 		q.resize()
 	}
 
 	q.items[q.end] = item
+	if q.sizer != nil {
+		q.size += q.sizer(item)
+	}
 	// Move the end position by 1. If we are already
 	// at the end of the slice, this will move "end"
 	// back to 0 since:
@@ -40,13 +54,17 @@ func (q *Queue) Push(item interface{}) {
 	q.count++
 }
 
-func (q *Queue) Pop() (interface{}, bool) {
+func (q *TypedQueue[T]) Pop() (T, bool) {
+	var zero T
 	if q.count == 0 {
-		return nil, false
+		return zero, false
 	}
 
 	item := q.items[q.start]
-	q.items[q.start] = nil
+	q.items[q.start] = zero
+	if q.sizer != nil {
+		q.size -= q.sizer(item)
+	}
 	// Move start forward by 1.
 	q.start = (q.start + 1) & (len(q.items) - 1)
 	q.count--
@@ -58,14 +76,80 @@ func (q *Queue) Pop() (interface{}, bool) {
 	return item, true
 }
 
-func (q *Queue) Length() int {
+// Peek returns the item at the head of the queue without removing it.
+func (q *TypedQueue[T]) Peek() (T, bool) {
+	if q.count == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.items[q.start], true
+}
+
+// Drain removes all items from the queue and returns them in order.
+// The queue shrinks back to its minimum size.
+func (q *TypedQueue[T]) Drain() []T {
+	if q.count == 0 {
+		return nil
+	}
+
+	items := make([]T, q.count)
+	if q.start < q.end {
+		copy(items, q.items[q.start:q.end])
+	} else {
+		n := copy(items, q.items[q.start:])
+		copy(items[n:], q.items[:q.end])
+	}
+
+	if len(q.items) > q.ms {
+		q.items = make([]T, q.ms)
+	} else {
+		// Clear the buffer so drained items can be garbage collected.
+		var zero T
+		for i := range q.items {
+			q.items[i] = zero
+		}
+	}
+	q.start, q.end, q.count, q.size = 0, 0, 0, 0
+	return items
+}
+
+// Length returns the number of items in the queue. It's the same as Len().
+func (q *TypedQueue[T]) Length() int {
+	return q.count
+}
+
+// Len returns the number of items in the queue.
+func (q *TypedQueue[T]) Len() int {
 	return q.count
 }
 
-func (q *Queue) resize() {
+// SetSizer sets a function estimating the size of items in bytes, enabling Size().
+func (q *TypedQueue[T]) SetSizer(sizer func(T) int) {
+	q.sizer = sizer
+	q.size = 0
+	if sizer == nil {
+		return
+	}
+	for i := 0; i < q.count; i++ {
+		q.size += sizer(q.items[(q.start+i)&(len(q.items)-1)])
+	}
+}
+
+// Size returns the estimated total size of queued items in bytes, or 0 if there's no sizer.
+func (q *TypedQueue[T]) Size() int {
+	return q.size
+}
+
+// Cap returns the current size of the underlying buffer.
+// It grows and shrinks with the number of items, but never goes below the minimum size.
+func (q *TypedQueue[T]) Cap() int {
+	return len(q.items)
+}
+
+func (q *TypedQueue[T]) resize() {
 	// Create a new buffer with double the current item count.
 	// This can shrink or grow the Queue, depending on the count.
-	items := make([]interface{}, q.count<<1)
+	items := make([]T, q.count<<1)
 
 	if q.start < q.end {
 		// If "end" position is ahead of "start",
@@ -0,0 +1,70 @@
+package librato
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Rate counts events and reports them as a per-second gauge, computed over each flush
+// interval. It's safe for concurrent use. See GetRate().
+type Rate struct {
+	name  string
+	count atomic.Int64
+	// since is when the current interval started, in Unix nanoseconds.
+	since atomic.Int64
+}
+
+// Add counts n events.
+func (r *Rate) Add(n int64) {
+	r.count.Add(n)
+}
+
+// Mark counts a single event.
+func (r *Rate) Mark() {
+	r.count.Add(1)
+}
+
+// GetRate returns the named rate, creating it if needed. Its value is reported as a
+// gauge with the number of events per second since the previous flush, including 0
+// when there were none.
+func (c *TimeCollatedClient) GetRate(name string) *Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.rates[name]
+	if !ok {
+		if c.rates == nil {
+			c.rates = make(map[string]*Rate)
+		}
+		r = &Rate{name: name}
+		r.since.Store(c.clock.Now().UnixNano())
+		c.rates[name] = r
+	}
+	return r
+}
+
+// rateMeasurements resets every rate and returns their per-second values as gauges.
+func (c *TimeCollatedClient) rateMeasurements(now time.Time) []Measurement {
+	c.mu.Lock()
+	rates := make([]*Rate, 0, len(c.rates))
+	for _, r := range c.rates {
+		rates = append(rates, r)
+	}
+	c.mu.Unlock()
+
+	var ms []Measurement
+	for _, r := range rates {
+		elapsed := time.Duration(now.UnixNano() - r.since.Swap(now.UnixNano()))
+		count := r.count.Swap(0)
+		if elapsed <= 0 {
+			r.count.Add(count)
+			continue
+		}
+
+		m := c.newMeasurement(KindGauge, r.name, float64(count)/elapsed.Seconds())
+		if c.prepare(&m) {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
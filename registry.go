@@ -0,0 +1,251 @@
+package librato
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metric is a registered gauge or counter, with its own channel and goroutine.
+type metric struct {
+	ch Chan
+	// lastUsed is the time the metric was last retrieved or pushed to, and lastPush
+	// the time a value was last pushed, in Unix nanoseconds.
+	lastUsed atomic.Int64
+	lastPush atomic.Int64
+
+	// users counts the pushes in progress, see useMetric(). Once the metric is retired,
+	// the last of them closes the channel.
+	users     atomic.Int32
+	retired   atomic.Bool
+	closeOnce sync.Once
+}
+
+func (m *metric) touch(t time.Time) {
+	m.lastUsed.Store(t.UnixNano())
+}
+
+// release ends a push started with useMetric().
+func (m *metric) release() {
+	if m.users.Add(-1) == 0 && m.retired.Load() {
+		m.close()
+	}
+}
+
+// retire closes the channel of a metric that was removed from the registry, as soon as
+// no push is using it. No new push can start, since they look metrics up under c.mu.
+func (m *metric) retire() {
+	m.retired.Store(true)
+	if m.users.Load() == 0 {
+		m.close()
+	}
+}
+
+func (m *metric) close() {
+	m.closeOnce.Do(m.ch.Close)
+}
+
+// RegistryEntry describes a registered gauge or counter. See Registry().
+type RegistryEntry struct {
+	Kind MetricKind
+	Name string
+	// LastPush is when a value was last received for the metric. It's zero if there weren't any.
+	LastPush time.Time
+	// Buffered is the number of values waiting to be collated.
+	Buffered int
+}
+
+// GaugeNames returns the names of all registered gauges, sorted.
+func (c *TimeCollatedClient) GaugeNames() []string {
+	return c.names(c.gauges)
+}
+
+// CounterNames returns the names of all registered counters, sorted.
+func (c *TimeCollatedClient) CounterNames() []string {
+	return c.names(c.counters)
+}
+
+func (c *TimeCollatedClient) names(metrics map[string]*metric) []string {
+	c.mu.Lock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Registry returns a snapshot of all registered gauges and counters, sorted by kind and name.
+// It's useful to debug what a process is reporting and to detect cardinality explosions.
+// Values pushed in dispatcher mode (see WithDispatcher()) don't register metrics.
+func (c *TimeCollatedClient) Registry() []RegistryEntry {
+	c.mu.Lock()
+	entries := make([]RegistryEntry, 0, len(c.gauges)+len(c.counters))
+	for kind, metrics := range map[MetricKind]map[string]*metric{KindGauge: c.gauges, KindCounter: c.counters} {
+		for name, m := range metrics {
+			e := RegistryEntry{Kind: kind, Name: name}
+			if b, ok := m.ch.(BufferedChan); ok {
+				e.Buffered = b.Len()
+			}
+			if t := m.lastPush.Load(); t != 0 {
+				e.LastPush = time.Unix(0, t)
+			}
+			entries = append(entries, e)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+func (c *TimeCollatedClient) getMetric(metrics map[string]*metric, kind MetricKind, name string, collate *TypedChan[Measurement]) Chan {
+	_, ch := c.lookupMetric(metrics, kind, name, collate, false)
+	return ch
+}
+
+// useMetric is like getMetric(), but keeps the metric from being retired until
+// release() is called, so that its channel isn't closed while pushing to it.
+// The metric is nil if the client is closed.
+func (c *TimeCollatedClient) useMetric(kind MetricKind, name string) (*metric, Chan) {
+	if kind == KindCounter {
+		return c.lookupMetric(c.counters, kind, name, c.collateCounters, true)
+	}
+	return c.lookupMetric(c.gauges, kind, name, c.collateGauges, true)
+}
+
+func (c *TimeCollatedClient) lookupMetric(metrics map[string]*metric, kind MetricKind, name string, collate *TypedChan[Measurement], use bool) (*metric, Chan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		// Close() closes the channels of existing metrics and the collator may already
+		// be closed, so values can't be sent anymore.
+		return nil, discardChan{}
+	}
+	m, ok := metrics[name]
+	if !ok {
+		m = &metric{ch: c.newMetricChan()}
+		metrics[name] = m
+		c.wg.Add(1)
+		go c.runMetric(kind, name, m, collate)
+	}
+	m.touch(c.clock.Now())
+	if use {
+		m.users.Add(1)
+	}
+	return m, m.ch
+}
+
+// RemoveGauge closes and unregisters the named gauge, stopping its goroutine.
+// Values already pushed are still sent, and so are those of PushGauge() calls
+// in progress. The channel previously returned by
+// GetGauge() must not be used afterwards; GetGauge() will create a new one.
+// It returns false if there's no such gauge.
+func (c *TimeCollatedClient) RemoveGauge(name string) bool {
+	return c.removeMetric(c.gauges, name)
+}
+
+// RemoveCounter closes and unregisters the named counter, stopping its goroutine.
+// See RemoveGauge().
+func (c *TimeCollatedClient) RemoveCounter(name string) bool {
+	return c.removeMetric(c.counters, name)
+}
+
+func (c *TimeCollatedClient) removeMetric(metrics map[string]*metric, name string) bool {
+	c.mu.Lock()
+	m, ok := metrics[name]
+	if c.closed {
+		// Close() owns the channels of all metrics.
+		ok = false
+	} else {
+		delete(metrics, name)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		m.retire()
+	}
+	return ok
+}
+
+// expireIdle periodically removes metrics that haven't been used for idleTTL,
+// until the client is closed.
+func (c *TimeCollatedClient) expireIdle() {
+	defer close(c.janitorDone)
+
+	t := c.clock.NewTicker(c.idleTTL / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.closing:
+			return
+		case now := <-t.C():
+			deadline := now.Add(-c.idleTTL).UnixNano()
+			var idle []*metric
+
+			c.mu.Lock()
+			for _, metrics := range []map[string]*metric{c.gauges, c.counters} {
+				for name, m := range metrics {
+					if m.lastUsed.Load() < deadline {
+						idle = append(idle, m)
+						delete(metrics, name)
+					}
+				}
+			}
+			c.mu.Unlock()
+
+			for _, m := range idle {
+				m.retire()
+			}
+		}
+	}
+}
+
+// discardInput is shared by every discardChan, and drained by a single goroutine
+// started on first use. closedOutput is their output.
+var (
+	discardInput     chan interface{}
+	discardInputOnce sync.Once
+	closedOutput     = make(chan interface{})
+)
+
+func init() {
+	close(closedOutput)
+}
+
+// discardChan is the Chan of metrics retrieved after Close(). It drops every value.
+type discardChan struct{}
+
+func (discardChan) Input() chan<- interface{} {
+	discardInputOnce.Do(func() {
+		discardInput = make(chan interface{})
+		go func() {
+			for range discardInput {
+			}
+		}()
+	})
+	return discardInput
+}
+
+func (discardChan) Output() <-chan interface{}                     { return closedOutput }
+func (discardChan) Close()                                         {}
+func (discardChan) Wait()                                          {}
+func (discardChan) Len() int                                       { return 0 }
+func (discardChan) Cap() int                                       { return 0 }
+func (discardChan) Push(item interface{})                          {}
+func (discardChan) TryPush(item interface{}) bool                  { return true }
+func (discardChan) PushContext(context.Context, interface{}) error { return nil }
+
+func (discardChan) PopContext(context.Context) (interface{}, bool, error) {
+	return nil, false, nil
+}
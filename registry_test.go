@@ -0,0 +1,41 @@
+package librato_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestRemoveGaugeWhilePushing(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour, librato.WithIdleTTL(time.Millisecond))
+	c.SetEndpoint(srv.Endpoint())
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					c.PushGauge("gauge", 1)
+				}
+			}
+		}()
+	}
+	for deadline := time.Now().Add(50 * time.Millisecond); time.Now().Before(deadline); {
+		c.RemoveGauge("gauge")
+	}
+	close(stop)
+	wg.Wait()
+	c.Close()
+	c.Wait()
+}
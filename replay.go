@@ -0,0 +1,39 @@
+package librato
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Replay reads batches previously written by a FileSink (one JSON batch per line)
+// and submits them to Librato in order. Measurements keep their original
+// measure_time, so it can be used to recover data spooled during an outage.
+//
+// Replay always posts to the Librato API, even if a custom sink is set.
+// Batches with an ID recorded in the ack log (see WithAckLog()) are skipped.
+// It stops at the first batch that fails, returning its error.
+func (c *TimeCollatedClient) Replay(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var batch Batch
+		if err := dec.Decode(&batch); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("replay: batch %d: %w", n, err)
+		}
+
+		if len(batch.Gauges) == 0 && len(batch.Counters) == 0 {
+			continue
+		}
+
+		if err := c.postBatch(ctx, &batch); err != nil {
+			return fmt.Errorf("replay: batch %d: %w", n, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package librato
+
+import (
+	"regexp"
+	"time"
+)
+
+// Resolution additionally reports matching metrics aggregated over a longer interval,
+// under a different name, e.g. "api.latency" every flush and "api.latency.1m" every
+// minute. See WithResolutions().
+type Resolution struct {
+	// Name is matched against metric names. A nil expression matches every metric.
+	Name *regexp.Regexp
+	// Interval is the length of each aggregation bucket, in whole seconds. Buckets are
+	// aligned to multiples of it, by measure_time.
+	Interval time.Duration
+	// Suffix is appended to the names of aggregated metrics, e.g. ".1m". It's required.
+	Suffix string
+}
+
+// resolutionKey identifies an aggregation bucket.
+type resolutionKey struct {
+	rule   int
+	kind   MetricKind
+	series string
+	start  int64
+}
+
+// resolutionBucket aggregates the measurements of a series in a single interval.
+// Gauges are summarized, counters keep their latest value.
+type resolutionBucket struct {
+	m        Measurement
+	summary  gaugeSummary
+	last     interface{}
+	lastTime int64
+}
+
+func (b *resolutionBucket) add(m *Measurement) bool {
+	if m.Kind == KindCounter {
+		if _, ok := toFloat64(m.Value); !ok {
+			return false
+		}
+		if m.MeasureTime >= b.lastTime {
+			b.last, b.lastTime = m.Value, m.MeasureTime
+		}
+		return true
+	}
+
+	s, ok := summarize(m)
+	if !ok {
+		return false
+	}
+	b.summary.merge(s)
+	return true
+}
+
+func (b *resolutionBucket) measurement() (Measurement, bool) {
+	m := b.m
+	if m.Kind == KindCounter {
+		m.Value = b.last
+		return m, b.last != nil
+	}
+	if b.summary.count == 0 {
+		return m, false
+	}
+	s := b.summary
+	m.Value = nil
+	m.Count, m.Sum, m.Min, m.Max = &s.count, &s.sum, &s.min, &s.max
+	if !s.noSumSquares {
+		m.SumSquares = &s.sumSquares
+	}
+	return m, true
+}
+
+// aggregateResolutions adds measurements to the buckets of matching resolutions and
+// returns those of completed buckets, or all buckets if final is set.
+func (c *TimeCollatedClient) aggregateResolutions(ms []Measurement, now time.Time, final bool) []Measurement {
+	for i, r := range c.resolutions {
+		interval := int64(r.Interval / time.Second)
+		if interval <= 0 || r.Suffix == "" {
+			continue
+		}
+		for j := range ms {
+			m := &ms[j]
+			if r.Name != nil && !r.Name.MatchString(m.Name) {
+				continue
+			}
+
+			start := m.MeasureTime - m.MeasureTime%interval
+			k := resolutionKey{i, m.Kind, seriesKey(m), start}
+			b, ok := c.buckets[k]
+			if !ok {
+				b = &resolutionBucket{m: Measurement{
+					Kind:        m.Kind,
+					Name:        m.Name + r.Suffix,
+					Source:      m.Source,
+					MeasureTime: start,
+					Tags:        m.Tags,
+					Period:      interval,
+				}}
+			}
+			if b.add(m) && !ok {
+				c.buckets[k] = b
+			}
+		}
+	}
+
+	// Measurements reach the collator a little after they're pushed, so buckets
+	// are only closed once a whole flush interval has passed since they ended.
+	cutoff := now.Add(-c.flushInterval() - time.Second).Unix()
+	var out []Measurement
+	for k, b := range c.buckets {
+		end := k.start + int64(c.resolutions[k.rule].Interval/time.Second)
+		if !final && end > cutoff {
+			continue
+		}
+		if m, ok := b.measurement(); ok {
+			out = append(out, m)
+		}
+		delete(c.buckets, k)
+	}
+	return out
+}
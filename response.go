@@ -0,0 +1,100 @@
+package librato
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is the state of a Librato rate limit, as reported in response headers.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	// Reset is when the limit resets.
+	Reset time.Time
+}
+
+// ResponseInfo describes the response to an API request. See LastResponseInfo().
+type ResponseInfo struct {
+	Method string
+	// URL is redacted, it never contains credentials.
+	URL        string
+	StatusCode int
+	// RequestID identifies the request to Librato support, if the response had one.
+	RequestID string
+	// RateLimits are keyed by the name of the limit, e.g. "std" or "agg".
+	RateLimits map[string]RateLimit
+	Time       time.Time
+	Header     http.Header
+}
+
+// requestIDHeaders are the headers that may identify a request, in order of preference.
+var requestIDHeaders = []string{"X-Librato-Request-Id", "X-Request-Id"}
+
+// rateLimitPrefix is the prefix of rate limit headers, e.g. X-Librato-RateLimit-Std,
+// whose value is e.g. "limit=300,remaining=299,reset=1700000000".
+const rateLimitPrefix = "X-Librato-Ratelimit-"
+
+func newResponseInfo(req *http.Request, res *http.Response, t time.Time) *ResponseInfo {
+	info := &ResponseInfo{
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		StatusCode: res.StatusCode,
+		Time:       t,
+		Header:     res.Header.Clone(),
+	}
+	for _, h := range requestIDHeaders {
+		if id := res.Header.Get(h); id != "" {
+			info.RequestID = id
+			break
+		}
+	}
+
+	for k, vs := range res.Header {
+		name, ok := strings.CutPrefix(k, rateLimitPrefix)
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		var rl RateLimit
+		for _, field := range strings.Split(vs[0], ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "limit":
+				rl.Limit = int(n)
+			case "remaining":
+				rl.Remaining = int(n)
+			case "reset":
+				rl.Reset = time.Unix(n, 0)
+			}
+		}
+		if info.RateLimits == nil {
+			info.RateLimits = make(map[string]RateLimit)
+		}
+		info.RateLimits[strings.ToLower(name)] = rl
+	}
+	return info
+}
+
+// recordResponse keeps the info of a response and passes it to the response handler.
+func (c *TimeCollatedClient) recordResponse(req *http.Request, res *http.Response) {
+	info := newResponseInfo(req, res, c.clock.Now())
+	c.lastResponse.Store(info)
+	if c.onResponse != nil {
+		c.onResponse(*info)
+	}
+}
+
+// LastResponseInfo returns the info of the last response to an API request, successful
+// or not, e.g. to include its request ID in a support issue. ok is false if there
+// weren't any responses yet.
+func (c *TimeCollatedClient) LastResponseInfo() (info ResponseInfo, ok bool) {
+	if p := c.lastResponse.Load(); p != nil {
+		return *p, true
+	}
+	return ResponseInfo{}, false
+}
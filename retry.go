@@ -0,0 +1,61 @@
+package librato
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides how failed batches are retried. Network errors,
+// 429 - Too Many Requests and 5xx responses are retried, others fail right away.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles for every
+	// following retry, up to MaxBackoff if it's set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// retryable reports whether a failed request may succeed if it's retried.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// deliver sends a batch to sink (see send()), retrying it according to the retry policy
+// until ctx is done. It returns the number of attempts made.
+func (c *TimeCollatedClient) deliver(ctx context.Context, batch *Batch, sink Sink) (int, error) {
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := c.send(ctx, batch, sink)
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryable(err) {
+			return attempt, err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		}
+		backoff *= 2
+		if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
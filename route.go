@@ -0,0 +1,79 @@
+package librato
+
+import (
+	"context"
+	"regexp"
+)
+
+// Route sends measurements of matching metrics somewhere other than the client's
+// default destination, or with a different source. See WithRoutes().
+type Route struct {
+	// Name is matched against metric names. A nil expression matches every metric.
+	Name *regexp.Regexp
+	// Source replaces the source of matching measurements, unless it's empty.
+	Source string
+	// Sink receives matching measurements instead of the client's sink or the API.
+	// Use another TimeCollatedClient to send them to a different account.
+	// A nil Sink keeps them with the rest.
+	Sink Sink
+}
+
+// routed holds measurements that go to a route's sink.
+type routed struct {
+	sink             Sink
+	gauges, counters []Measurement
+}
+
+// route applies routes to the measurements of a flush. It returns the ones that stay
+// with the client, and those that go to the sink of each route.
+func (c *TimeCollatedClient) route(gauges, counters []Measurement) ([]Measurement, []Measurement, []routed) {
+	out := make([]routed, len(c.routes))
+	split := func(ms []Measurement, counters bool) []Measurement {
+		kept := ms[:0]
+		for _, m := range ms {
+			i := c.matchRoute(&m)
+			if i < 0 {
+				kept = append(kept, m)
+				continue
+			}
+			r := &c.routes[i]
+			if r.Source != "" {
+				m.Source = r.Source
+			}
+			switch {
+			case r.Sink == nil:
+				kept = append(kept, m)
+			case counters:
+				out[i].counters = append(out[i].counters, m)
+			default:
+				out[i].gauges = append(out[i].gauges, m)
+			}
+		}
+		return kept
+	}
+	gauges = split(gauges, false)
+	counters = split(counters, true)
+
+	for i := range out {
+		out[i].sink = c.routes[i].Sink
+	}
+	return gauges, counters, out
+}
+
+// matchRoute returns the index of the first route matching m, or -1.
+func (c *TimeCollatedClient) matchRoute(m *Measurement) int {
+	for i, r := range c.routes {
+		if r.Name == nil || r.Name.MatchString(m.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Send delivers a batch right away to the client's sink or the API, retrying it
+// according to the retry policy. It bypasses collation and the pipeline, and makes
+// the client usable as a Sink, e.g. as the destination of a Route.
+func (c *TimeCollatedClient) Send(ctx context.Context, batch *Batch) error {
+	_, err := c.deliver(ctx, batch, nil)
+	return err
+}
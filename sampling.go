@@ -0,0 +1,70 @@
+package librato
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// samplers holds sample rates by metric name. See WithSampleRates().
+type samplers struct {
+	mu    sync.RWMutex
+	rates map[string]float64
+}
+
+func (s *samplers) rate(name string) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rate, ok := s.rates[name]
+	return rate, ok
+}
+
+func (s *samplers) set(name string, rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rate >= 1 {
+		delete(s.rates, name)
+		return
+	}
+	s.rates[name] = rate
+}
+
+// sample decides whether to keep m based on the sample rate of its name. Kept
+// pre-aggregated gauges have their count, sum and sum of squares scaled up, so
+// they still represent all observations. So do counter values if increments is set.
+func (s *samplers) sample(m *Measurement, increments bool) bool {
+	rate, ok := s.rate(m.Name)
+	if !ok {
+		return true
+	}
+	if rate <= 0 || rand.Float64() >= rate {
+		return false
+	}
+
+	// Gauge values are point in time, and so are counter values unless they're
+	// increments (see WithCounterSums()), in which case they're scaled like aggregates.
+	if increments && m.Kind == KindCounter {
+		if i, ok := integer(m.Value); ok {
+			m.Value = int64(math.Round(float64(i) / rate))
+		} else if f, ok := toFloat64(m.Value); ok {
+			m.Value = f / rate
+		}
+	}
+	if m.Count != nil {
+		n := int64(float64(*m.Count)/rate + 0.5)
+		m.Count = &n
+	}
+	for _, f := range []**float64{&m.Sum, &m.SumSquares} {
+		if *f != nil {
+			v := **f / rate
+			*f = &v
+		}
+	}
+	return true
+}
+
+// SetSampleRate changes the sample rate of a metric at runtime, see WithSampleRates().
+// A rate of 1 or more disables sampling for the metric.
+func (c *TimeCollatedClient) SetSampleRate(name string, rate float64) {
+	c.sampling.set(name, rate)
+}
@@ -0,0 +1,25 @@
+package librato
+
+import "testing"
+
+func TestSampleScalesCounterIncrements(t *testing.T) {
+	s := &samplers{rates: map[string]float64{"requests": 0.5}}
+	for _, tc := range []struct {
+		increments bool
+		want       interface{}
+	}{
+		{false, int64(10)},
+		{true, int64(20)},
+	} {
+		for {
+			m := Measurement{Kind: KindCounter, Name: "requests", Value: int64(10)}
+			if !s.sample(&m, tc.increments) {
+				continue
+			}
+			if m.Value != tc.want {
+				t.Errorf("increments=%v: got %v, want %v", tc.increments, m.Value, tc.want)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Service is a notification destination for alerts, e.g. Slack, PagerDuty or a webhook.
+// http://api-docs-archive.librato.com/#services
+type Service struct {
+	ID int `json:"id,omitempty"`
+	// Type is the kind of service, e.g. "slack", "pagerduty" or "campfire".
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// Settings depend on the type, e.g. "url" for webhooks.
+	Settings map[string]string `json:"settings"`
+}
+
+// ListServices returns a page of the account's services.
+func (c *TimeCollatedClient) ListServices(ctx context.Context, opts *ListOptions) ([]Service, QueryInfo, error) {
+	return listPage[Service](ctx, c, "/services", "services", opts.values())
+}
+
+// GetService returns the service with the given ID.
+func (c *TimeCollatedClient) GetService(ctx context.Context, id int) (*Service, error) {
+	s := &Service{}
+	if err := c.apiRequest(ctx, http.MethodGet, fmt.Sprintf("/services/%d", id), nil, nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateService creates a service and returns it with its ID.
+func (c *TimeCollatedClient) CreateService(ctx context.Context, s *Service) (*Service, error) {
+	created := &Service{}
+	if err := c.apiRequest(ctx, http.MethodPost, "/services", nil, s, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateService replaces the title and settings of the service with s.ID.
+func (c *TimeCollatedClient) UpdateService(ctx context.Context, s *Service) error {
+	return c.apiRequest(ctx, http.MethodPut, fmt.Sprintf("/services/%d", s.ID), nil, s, nil)
+}
+
+// DeleteService deletes the service with the given ID.
+func (c *TimeCollatedClient) DeleteService(ctx context.Context, id int) error {
+	return c.apiRequest(ctx, http.MethodDelete, fmt.Sprintf("/services/%d", id), nil, nil, nil)
+}
@@ -0,0 +1,149 @@
+package librato
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// DefaultSetExactLimit is the number of distinct values a Set counts exactly in an
+// interval before switching to HyperLogLog. See WithSetExactLimit().
+const DefaultSetExactLimit = 10000
+
+// Set counts distinct values, like statsd sets, and reports their number as a gauge
+// for each flush interval. It's safe for concurrent use. See GetSet().
+type Set struct {
+	name  string
+	limit int
+
+	mu     sync.Mutex
+	values map[string]struct{}
+	hll    *hyperLogLog
+}
+
+// Add adds a value to the set.
+func (s *Set) Add(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hll != nil {
+		s.hll.add(v)
+		return
+	}
+	s.values[v] = struct{}{}
+	if len(s.values) > s.limit {
+		// Too many values to keep around, estimate from now on.
+		s.hll = &hyperLogLog{}
+		for v := range s.values {
+			s.hll.add(v)
+		}
+		s.values = nil
+	}
+}
+
+// reset returns the number of distinct values added since the last call and empties the set.
+func (s *Set) reset() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n float64
+	if s.hll != nil {
+		n = s.hll.count()
+		s.hll = nil
+	} else {
+		n = float64(len(s.values))
+	}
+	s.values = make(map[string]struct{})
+	return n
+}
+
+// GetSet returns the named set, creating it if needed. The number of distinct values
+// added in each flush interval is reported as a gauge, including 0 when there were none.
+// It's exact up to the limit set with WithSetExactLimit(), and an estimate with a
+// standard error of about 1% beyond it.
+func (c *TimeCollatedClient) GetSet(name string) *Set {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.sets[name]
+	if !ok {
+		if c.sets == nil {
+			c.sets = make(map[string]*Set)
+		}
+		s = &Set{name: name, limit: c.setExactLimit, values: make(map[string]struct{})}
+		c.sets[name] = s
+	}
+	return s
+}
+
+// setMeasurements resets every set and returns their sizes as gauges.
+func (c *TimeCollatedClient) setMeasurements() []Measurement {
+	c.mu.Lock()
+	sets := make([]*Set, 0, len(c.sets))
+	for _, s := range c.sets {
+		sets = append(sets, s)
+	}
+	c.mu.Unlock()
+
+	var ms []Measurement
+	for _, s := range sets {
+		m := c.newMeasurement(KindGauge, s.name, s.reset())
+		if c.prepare(&m) {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// hyperLogLog estimates the number of distinct values with 2^14 registers.
+// https://algo.inria.fr/flajolet/Publications/FlFuGaMe07.pdf
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+const hllPrecision = 14
+
+func (h *hyperLogLog) add(v string) {
+	f := fnv.New64a()
+	f.Write([]byte(v))
+	x := mix64(f.Sum64())
+
+	i := x >> (64 - hllPrecision)
+	// Guard bit, so the rank is at most 64 - precision + 1.
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	if rank := uint8(bits.LeadingZeros64(w) + 1); rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h *hyperLogLog) count() float64 {
+	const m = float64(1 << hllPrecision)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return math.Round(estimate)
+}
+
+// mix64 is the finalizer of MurmurHash3, spreading FNV's bits over the whole word.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
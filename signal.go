@@ -0,0 +1,48 @@
+package librato
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// FlushOnSignal installs a handler that closes the client and waits for the final flush
+// when one of the given signals is received, e.g. os.Interrupt or syscall.SIGTERM.
+// The handler is then removed and the signal raised again, so the process exits as it
+// would have without it. If the signal can't be raised again, the process exits with
+// status 1.
+//
+// Applications that handle these signals themselves should call Close() and Wait()
+// from their own handler instead. The returned function removes the handler.
+func (c *TimeCollatedClient) FlushOnSignal(sigs ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+
+	go func() {
+		select {
+		case sig := <-ch:
+			stop()
+			c.Close()
+			c.Wait()
+
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(sig)
+			}
+			if err != nil {
+				os.Exit(1)
+			}
+		case <-done:
+		}
+	}()
+	return stop
+}
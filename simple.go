@@ -0,0 +1,113 @@
+package librato
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// SimpleClient posts measurements synchronously, without collation or background
+// goroutines. It's meant for CLIs, cron jobs and functions such as AWS Lambda,
+// which send a few measurements once and exit.
+type SimpleClient struct {
+	c *TimeCollatedClient
+}
+
+// NewSimpleClient creates a SimpleClient. Measurements without a source are sent with
+// the given one, see NewTimeCollatedClient(). Options that configure requests, such as
+// WithRetry(), WithRequestTimeout() or WithDefaultTags(), are applied, and so are those
+// that name and validate measurements, such as WithPrefix(), WithRenameRules() and
+// WithNonFinite(). Those that configure collation have no effect.
+func NewSimpleClient(user, token, source string, opts ...Option) *SimpleClient {
+	return &SimpleClient{c: newClient(user, token, source, 0, opts)}
+}
+
+// SetEndpoint sets the base URL of the Librato API, see TimeCollatedClient.SetEndpoint().
+func (s *SimpleClient) SetEndpoint(endpoint string) {
+	s.c.endpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+// SetHTTPClient sets the HTTP client used for requests.
+func (s *SimpleClient) SetHTTPClient(client *http.Client) {
+	s.c.client = client
+}
+
+// SubmitGauges posts gauge measurements right away, in as many requests as needed.
+func (s *SimpleClient) SubmitGauges(ctx context.Context, gauges []Measurement) error {
+	return s.Submit(ctx, &Batch{Gauges: gauges})
+}
+
+// SubmitCounters posts counter measurements right away, in as many requests as needed.
+func (s *SimpleClient) SubmitCounters(ctx context.Context, counters []Measurement) error {
+	return s.Submit(ctx, &Batch{Counters: counters})
+}
+
+// Submit posts a batch right away, retrying it according to the retry policy.
+// Batches larger than MaxMetrics are split. It returns the first error, either
+// from validation or from the API, without sending the remaining measurements.
+func (s *SimpleClient) Submit(ctx context.Context, batch *Batch) error {
+	gauges, err := s.prepare(batch.Gauges, KindGauge)
+	if err != nil {
+		return err
+	}
+	counters, err := s.prepare(batch.Counters, KindCounter)
+	if err != nil {
+		return err
+	}
+
+	for len(gauges) > 0 || len(counters) > 0 {
+		b := &Batch{ID: newBatchID()}
+		n := min(len(gauges), MaxMetrics)
+		b.Gauges, gauges = gauges[:n:n], gauges[n:]
+		m := min(len(counters), MaxMetrics-n)
+		b.Counters, counters = counters[:m:m], counters[m:]
+
+		if _, err := s.c.deliver(ctx, b, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare returns a copy of ms with the default source and kind set, renamed, prefixed
+// and validated the way TimeCollatedClient prepares pushed measurements. Non-finite
+// values the policy drops are returned as an error, see WithNonFinite().
+func (s *SimpleClient) prepare(ms []Measurement, kind MetricKind) ([]Measurement, error) {
+	source := *s.c.source.Load()
+	out := make([]Measurement, len(ms))
+	for i, m := range ms {
+		m.Kind = kind
+		if m.Source == "" {
+			m.Source = source
+		}
+		for j := range s.c.renameRules {
+			s.c.renameRules[j].apply(&m)
+		}
+		m.Name = s.c.prefix + m.Name
+		if err := s.c.validate(&m); err != nil {
+			return nil, err
+		}
+		keep, err := s.c.checkFinite(&m)
+		if !keep {
+			return nil, err
+		}
+		if err != nil {
+			s.c.reportError(err)
+		}
+		out[i] = m
+	}
+	return out, nil
+}
+
+// PostAnnotation posts an annotation to the named stream.
+func (s *SimpleClient) PostAnnotation(ctx context.Context, body *Annotation, stream string) error {
+	if stream == "" {
+		return ErrNoNameAnnotation
+	}
+	buf, err := s.c.encode(body)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+	return s.c.makeRequest(ctx, buf, s.c.endpoint+"/annotations/"+stream)
+}
@@ -0,0 +1,30 @@
+package librato_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestSimpleClientPreparesLikeCollatedClient(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	s := librato.NewSimpleClient("user", "token", "source",
+		librato.WithPrefix("app."), librato.WithNonFinite(librato.NonFiniteDrop))
+	s.SetEndpoint(srv.Endpoint())
+
+	if err := s.SubmitGauges(context.Background(), []librato.Measurement{{Name: "up", Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SubmitGauges(context.Background(), []librato.Measurement{{Name: "bad", Value: math.NaN()}}); err == nil {
+		t.Error("NaN was accepted")
+	}
+
+	batches := srv.Batches()
+	if len(batches) != 1 || len(batches[0].Gauges) != 1 || batches[0].Gauges[0].Name != "app.up" {
+		t.Errorf("got batches %+v, want only app.up", batches)
+	}
+}
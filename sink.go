@@ -0,0 +1,152 @@
+package librato
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Batch is a single collated payload, in the format accepted by the Librato metrics API.
+type Batch struct {
+	// ID is generated by the client for each batch. It's not sent to Librato, but
+	// it's kept by sinks such as FileSink, so that replayed batches already accepted
+	// by the API can be skipped. See WithAckLog().
+	ID       string        `json:"id,omitempty"`
+	Gauges   []Measurement `json:"gauges,omitempty"`
+	Counters []Measurement `json:"counters,omitempty"`
+}
+
+// UnmarshalJSON decodes values as json.Number, so that integers are encoded
+// again exactly as they were, e.g. by Replay().
+func (b *Batch) UnmarshalJSON(data []byte) error {
+	type batch Batch
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*batch)(b)); err != nil {
+		return err
+	}
+	for i := range b.Counters {
+		b.Counters[i].Kind = KindCounter
+	}
+	return nil
+}
+
+// Sink receives collated batches in place of the Librato API.
+// See TimeCollatedClient.SetSink().
+type Sink interface {
+	// Send delivers a single batch. It's only called concurrently by the client
+	// if there are multiple flush workers, see WithFlushWorkers().
+	Send(ctx context.Context, batch *Batch) error
+}
+
+// FileSink writes each batch as a single line of JSON to a file,
+// rotating it once it grows beyond a given size. It's useful for
+// local development without credentials and for capturing payloads
+// to be replayed later.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) the file at path for appending. Once the file
+// reaches maxSize bytes it's rotated to path.1, path.1 to path.2 and so on,
+// keeping at most maxBackups old files. A maxSize of 0 disables rotation.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Send(ctx context.Context, batch *Batch) error {
+	b, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+
+	// Never rotate an empty file, even if a single batch is larger than maxSize.
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the underlying file. Batches sent after Close will fail.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	s.f = nil
+
+	if s.maxBackups > 0 {
+		// Shift path.N-1 to path.N, ..., path.1 to path.2, overwriting the oldest.
+		for i := s.maxBackups - 1; i > 0; i-- {
+			from := fmt.Sprintf("%s.%d", s.path, i)
+			if _, err := os.Stat(from); err == nil {
+				if err := os.Rename(from, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
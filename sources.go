@@ -0,0 +1,45 @@
+package librato
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Source is a source measurements were submitted with.
+// http://api-docs-archive.librato.com/#sources
+type Source struct {
+	Name string `json:"name"`
+	// DisplayName is shown instead of the name in charts, if it's set.
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// ListSources returns a page of the account's sources. If name isn't empty, only
+// sources whose names contain it are returned.
+func (c *TimeCollatedClient) ListSources(ctx context.Context, name string, opts *ListOptions) ([]Source, QueryInfo, error) {
+	q := opts.values()
+	if name != "" {
+		q.Set("name", name)
+	}
+	return listPage[Source](ctx, c, "/sources", "sources", q)
+}
+
+// GetSource returns the named source.
+func (c *TimeCollatedClient) GetSource(ctx context.Context, name string) (*Source, error) {
+	s := &Source{}
+	if err := c.apiRequest(ctx, http.MethodGet, "/sources/"+url.PathEscape(name), nil, nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateSource sets the display name of the source named s.Name.
+func (c *TimeCollatedClient) UpdateSource(ctx context.Context, s *Source) error {
+	body := map[string]string{"display_name": s.DisplayName}
+	return c.apiRequest(ctx, http.MethodPut, "/sources/"+url.PathEscape(s.Name), nil, body, nil)
+}
+
+// DeleteSource deletes the named source and all of its measurements.
+func (c *TimeCollatedClient) DeleteSource(ctx context.Context, name string) error {
+	return c.apiRequest(ctx, http.MethodDelete, "/sources/"+url.PathEscape(name), nil, nil, nil)
+}
@@ -0,0 +1,100 @@
+package librato
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Space is a dashboard of charts.
+// http://api-docs-archive.librato.com/#spaces
+type Space struct {
+	ID   int    `json:"id,omitempty" yaml:"-"`
+	Name string `json:"name" yaml:"name"`
+	// Charts are only used by SyncDashboards(). The API returns them without their
+	// definitions, see ListCharts().
+	Charts []Chart `json:"-" yaml:"charts"`
+}
+
+// Chart is a chart of a space.
+// http://api-docs-archive.librato.com/#charts
+type Chart struct {
+	ID   int    `json:"id,omitempty" yaml:"-"`
+	Name string `json:"name" yaml:"name"`
+	// Type is "line", "stacked" or "bignumber". Librato defaults to "line".
+	Type    string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Streams []Stream `json:"streams" yaml:"streams"`
+	// Min and Max set the range of the Y axis, and Label its label.
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	Label string   `json:"label,omitempty" yaml:"label,omitempty"`
+}
+
+// Stream is a series shown in a chart, either a metric or a composite expression.
+type Stream struct {
+	Metric string `json:"metric,omitempty" yaml:"metric,omitempty"`
+	// Source is a source name or pattern, e.g. "web*" or "*".
+	Source    string `json:"source,omitempty" yaml:"source,omitempty"`
+	Composite string `json:"composite,omitempty" yaml:"composite,omitempty"`
+	// GroupFunction and SummaryFunction are e.g. "average", "sum", "min" or "max".
+	GroupFunction   string `json:"group_function,omitempty" yaml:"group_function,omitempty"`
+	SummaryFunction string `json:"summary_function,omitempty" yaml:"summary_function,omitempty"`
+	Name            string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// ListSpaces returns a page of the account's spaces.
+func (c *TimeCollatedClient) ListSpaces(ctx context.Context, opts *ListOptions) ([]Space, QueryInfo, error) {
+	return listPage[Space](ctx, c, "/spaces", "spaces", opts.values())
+}
+
+// Spaces iterates over all of the account's spaces, starting at opts.Offset.
+func (c *TimeCollatedClient) Spaces(ctx context.Context, opts *ListOptions) *Iterator[Space] {
+	return newIterator[Space](ctx, c, "/spaces", "spaces", opts.values())
+}
+
+// CreateSpace creates a space and returns it with its ID. Its charts aren't created.
+func (c *TimeCollatedClient) CreateSpace(ctx context.Context, s *Space) (*Space, error) {
+	created := &Space{}
+	if err := c.apiRequest(ctx, http.MethodPost, "/spaces", nil, s, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateSpace renames the space with s.ID.
+func (c *TimeCollatedClient) UpdateSpace(ctx context.Context, s *Space) error {
+	return c.apiRequest(ctx, http.MethodPut, fmt.Sprintf("/spaces/%d", s.ID), nil, s, nil)
+}
+
+// DeleteSpace deletes the space with the given ID and its charts.
+func (c *TimeCollatedClient) DeleteSpace(ctx context.Context, id int) error {
+	return c.apiRequest(ctx, http.MethodDelete, fmt.Sprintf("/spaces/%d", id), nil, nil, nil)
+}
+
+// ListCharts returns all charts of the space with the given ID.
+func (c *TimeCollatedClient) ListCharts(ctx context.Context, spaceID int) ([]Chart, error) {
+	var charts []Chart
+	if err := c.apiRequest(ctx, http.MethodGet, fmt.Sprintf("/spaces/%d/charts", spaceID), nil, nil, &charts); err != nil {
+		return nil, err
+	}
+	return charts, nil
+}
+
+// CreateChart creates a chart in the space with the given ID and returns it with its ID.
+func (c *TimeCollatedClient) CreateChart(ctx context.Context, spaceID int, ch *Chart) (*Chart, error) {
+	created := &Chart{}
+	if err := c.apiRequest(ctx, http.MethodPost, fmt.Sprintf("/spaces/%d/charts", spaceID), nil, ch, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateChart replaces the chart with ch.ID in the space with the given ID.
+func (c *TimeCollatedClient) UpdateChart(ctx context.Context, spaceID int, ch *Chart) error {
+	return c.apiRequest(ctx, http.MethodPut, fmt.Sprintf("/spaces/%d/charts/%d", spaceID, ch.ID), nil, ch, nil)
+}
+
+// DeleteChart deletes the chart with the given ID from the space with spaceID.
+func (c *TimeCollatedClient) DeleteChart(ctx context.Context, spaceID, id int) error {
+	return c.apiRequest(ctx, http.MethodDelete, fmt.Sprintf("/spaces/%d/charts/%d", spaceID, id), nil, nil, nil)
+}
@@ -0,0 +1,70 @@
+package librato
+
+import "sync"
+
+// state is the Aggregator of a gauge set with SetState().
+type state struct {
+	// repeat is set by WithRepeatedStates().
+	repeat bool
+
+	mu sync.Mutex
+	ok bool
+	// fresh is set until the state is flushed.
+	fresh bool
+}
+
+func (s *state) set(ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ok, s.fresh = ok, true
+}
+
+// Add sets the state from a pushed value, which is ok if it's true or a non-zero number.
+// Other values are ignored.
+func (s *state) Add(value interface{}) {
+	if b, isBool := value.(bool); isBool {
+		s.set(b)
+	} else if f, ok := toFloat64(value); ok {
+		s.set(f != 0)
+	}
+}
+
+// Flush returns the state as a gauge, if it was set since the last flush or it's repeated.
+func (s *state) Flush() []Measurement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.fresh && !s.repeat {
+		return nil
+	}
+	s.fresh = false
+
+	v := 0
+	if s.ok {
+		v = 1
+	}
+	return []Measurement{{Kind: KindGauge, Value: v}}
+}
+
+// SetState sets the named boolean state, reported as a gauge of 1 if ok and 0 otherwise,
+// e.g. for "is the consumer healthy" indicators. Only the last state of each flush
+// interval is sent. Unless WithRepeatedStates() is used, a state is only sent in
+// intervals where it's set. The state is the gauge's aggregator, replacing another one,
+// see SetGaugeAggregator().
+func (c *TimeCollatedClient) SetState(name string, ok bool) {
+	aggregatorOf(&c.aggregators, aggregatorKey{KindGauge, name}, func() *state {
+		return &state{repeat: c.repeatStates}
+	}).set(ok)
+}
+
+// ClearState stops reporting the named state, see SetState().
+func (c *TimeCollatedClient) ClearState(name string) {
+	key := aggregatorKey{KindGauge, name}
+	c.aggregators.mu.Lock()
+	defer c.aggregators.mu.Unlock()
+	if a := c.aggregators.get(key); a != nil {
+		if _, ok := a.agg.(*state); ok {
+			c.aggregators.setLocked(key, nil)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package librato_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dcelasun/librato"
+	"github.com/dcelasun/librato/libratotest"
+)
+
+func TestState(t *testing.T) {
+	srv := libratotest.NewServer()
+	defer srv.Close()
+	c := librato.NewTimeCollatedClient("user", "token", "source", time.Hour)
+	c.SetEndpoint(srv.Endpoint())
+
+	c.SetState("healthy", true)
+	c.SetState("healthy", false)
+	// Pushed values set the state too.
+	c.PushGauge("healthy", 1)
+	c.SetState("cleared", true)
+	c.ClearState("cleared")
+	c.Close()
+	c.Wait()
+
+	var got []librato.Measurement
+	for _, b := range srv.Batches() {
+		got = append(got, b.Gauges...)
+	}
+	if len(got) != 1 || got[0].Name != "healthy" || fmt.Sprint(got[0].Value) != "1" {
+		t.Errorf("got gauges %+v, want only healthy = 1", got)
+	}
+}
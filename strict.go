@@ -0,0 +1,119 @@
+package librato
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Librato limits tag names to 64 characters of A-Za-z0-9.:-_ and tag values to
+// 255 characters, which may also include spaces, slashes and question marks.
+// http://api-docs-archive.librato.com/#create-a-measurement
+const (
+	maxTagNameLength  = 64
+	maxTagValueLength = 255
+)
+
+// checkStrict checks m against the constraints documented by Librato, beyond its name
+// and source, returning a *ValidationError that lists every violation. See ValidateStrict.
+func checkStrict(m *Measurement) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case m.Value != nil:
+		if m.Count != nil || m.Sum != nil {
+			add("value can't be combined with count and sum")
+		}
+		if !numeric(m.Value) {
+			add("value %v (%T) is not a number", m.Value, m.Value)
+		} else if m.Kind == KindCounter && !integral(m.Value) {
+			add("counter value %v is not an integer", m.Value)
+		}
+	case m.Kind == KindCounter:
+		add("counter value is required")
+	case m.Count == nil || m.Sum == nil:
+		add("either value, or count and sum are required")
+	}
+
+	if m.Count != nil && *m.Count <= 0 {
+		add("count must be positive, got %d", *m.Count)
+	}
+	if m.Min != nil && m.Max != nil && *m.Min > *m.Max {
+		add("min %v is greater than max %v", *m.Min, *m.Max)
+	}
+	if m.SumSquares != nil && *m.SumSquares < 0 {
+		add("sum_squares must not be negative, got %v", *m.SumSquares)
+	}
+	if m.MeasureTime < 0 {
+		add("measure_time must not be negative, got %d", m.MeasureTime)
+	}
+	if m.Period < 0 {
+		add("period must not be negative, got %d", m.Period)
+	}
+
+	for k, v := range m.Tags {
+		if k == "" || len(k) > maxTagNameLength || !validName(k) {
+			add("tag name %q must be 1 to %d characters of A-Za-z0-9.:-_", k, maxTagNameLength)
+		}
+		if v == "" || len(v) > maxTagValueLength || !validTagValue(v) {
+			add("value %q of tag %q must be 1 to %d characters of A-Za-z0-9.:-_/\\? and spaces", v, k, maxTagValueLength)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Name: m.Name, Source: m.Source, Reason: strings.Join(problems, "; ")}
+}
+
+// strictBatch checks every measurement of a batch right before it's sent, since
+// transforms and aggregation run after measurements are first validated.
+func (c *TimeCollatedClient) strictBatch(batch *Batch) error {
+	var errs []error
+	for _, ms := range [][]Measurement{batch.Gauges, batch.Counters} {
+		for i := range ms {
+			m := ms[i]
+			if err := c.validate(&m); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("strict validation: %w", errors.Join(errs...))
+}
+
+func numeric(v interface{}) bool {
+	_, ok := toFloat64(v)
+	return ok
+}
+
+func integral(v interface{}) bool {
+	switch n := v.(type) {
+	case json.Number:
+		_, err := n.Int64()
+		return err == nil
+	case float32, float64:
+		f, _ := toFloat64(v)
+		return f == float64(int64(f))
+	case uint, uint64, uintptr:
+		// Values too large for an int64 are still integers.
+		return true
+	}
+	_, ok := toInt64(v)
+	return ok
+}
+
+func validTagValue(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !validChar(c) && c != ' ' && c != '/' && c != '\\' && c != '?' {
+			return false
+		}
+	}
+	return true
+}
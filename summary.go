@@ -0,0 +1,65 @@
+package librato
+
+import "sync"
+
+// Summary is the outcome of a client's deliveries, see WaitResult().
+type Summary struct {
+	// Batches and Measurements count what was delivered successfully.
+	Batches      int
+	Measurements int
+	// FailedBatches and FailedMeasurements count what was given up on after retries.
+	FailedBatches      int
+	FailedMeasurements int
+	// Errors is the number of errors reported in the background, including failed
+	// batches, and LastError the most recent one.
+	Errors    int
+	LastError error
+}
+
+// OK reports whether everything was delivered without errors.
+func (s Summary) OK() bool {
+	return s.Errors == 0 && s.FailedBatches == 0
+}
+
+// summary accumulates a Summary as batches are sent.
+type summary struct {
+	mu sync.Mutex
+	s  Summary
+}
+
+func (s *summary) batch(n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.s.FailedBatches++
+		s.s.FailedMeasurements += n
+	} else {
+		s.s.Batches++
+		s.s.Measurements += n
+	}
+}
+
+func (s *summary) error(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.Errors++
+	s.s.LastError = err
+}
+
+func (s *summary) get() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s
+}
+
+// WaitResult is like Wait(), but also returns a summary of everything the client
+// delivered, e.g. so that batch jobs can log whether their metrics made it out.
+func (c *TimeCollatedClient) WaitResult() Summary {
+	c.Wait()
+	return c.summary.get()
+}
+
+// Summary returns a snapshot of what the client delivered so far, see WaitResult().
+func (c *TimeCollatedClient) Summary() Summary {
+	return c.summary.get()
+}
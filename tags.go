@@ -0,0 +1,121 @@
+package librato
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// taggedPayload is the body of a request to the tagged measurements API.
+// http://api-docs-archive.librato.com/#create-a-measurement
+type taggedPayload struct {
+	Tags         map[string]string   `json:"tags,omitempty"`
+	Measurements []taggedMeasurement `json:"measurements"`
+}
+
+type taggedMeasurement struct {
+	Name       string            `json:"name"`
+	Value      interface{}       `json:"value,omitempty"`
+	Time       int64             `json:"time,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Period     int64             `json:"period,omitempty"`
+	Count      *int64            `json:"count,omitempty"`
+	Sum        *float64          `json:"sum,omitempty"`
+	Min        *float64          `json:"min,omitempty"`
+	Max        *float64          `json:"max,omitempty"`
+	SumSquares *float64          `json:"sum_squares,omitempty"`
+}
+
+// mergeDefaultTags adds detected tags to the default tags. Tags set explicitly,
+// e.g. with WithDefaultTags(), take precedence.
+func (c *TimeCollatedClient) mergeDefaultTags(tags map[string]string) {
+	for k, v := range c.defaultTags {
+		tags[k] = v
+	}
+	c.defaultTags = tags
+}
+
+// newTaggedPayload converts a batch to the tagged measurements format. There are no
+// counters in that API, so they are sent as gauges. Sources become a "source" tag.
+func (c *TimeCollatedClient) newTaggedPayload(batch *Batch) *taggedPayload {
+	p := &taggedPayload{
+		Tags:         c.defaultTags,
+		Measurements: make([]taggedMeasurement, 0, len(batch.Gauges)+len(batch.Counters)),
+	}
+	for _, ms := range [][]Measurement{batch.Gauges, batch.Counters} {
+		for _, m := range ms {
+			// Measurement tags may replace top level tags rather than add to
+			// them, so merge the default tags in explicitly.
+			tags := m.Tags
+			if len(m.Tags) > 0 || m.Source != "" {
+				tags = make(map[string]string, len(c.defaultTags)+len(m.Tags)+1)
+				for k, v := range c.defaultTags {
+					tags[k] = v
+				}
+				if m.Source != "" {
+					tags["source"] = m.Source
+				}
+				for k, v := range m.Tags {
+					tags[k] = v
+				}
+			}
+
+			p.Measurements = append(p.Measurements, taggedMeasurement{
+				Name:       m.Name,
+				Value:      m.Value,
+				Time:       m.MeasureTime,
+				Tags:       tags,
+				Period:     m.Period,
+				Count:      m.Count,
+				Sum:        m.Sum,
+				Min:        m.Min,
+				Max:        m.Max,
+				SumSquares: m.SumSquares,
+			})
+		}
+	}
+	return p
+}
+
+// ParseTags parses tags in the "key=value,key2=value2" format, as used by LIBRATO_TAGS.
+func ParseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected key=value", pair)
+		}
+		tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return tags, nil
+}
+
+// sourceTemplateData is available to templates set with WithSourceTemplate().
+type sourceTemplateData struct {
+	Hostname string
+	Source   string
+	Tags     map[string]string
+}
+
+// renderSource executes the source template, returning the new default source.
+func (c *TimeCollatedClient) renderSource(source string) (string, error) {
+	tmpl, err := template.New("source").Funcs(template.FuncMap{"env": os.Getenv}).Parse(c.sourceTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	hostname, _ := os.Hostname()
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, sourceTemplateData{
+		Hostname: hostname,
+		Source:   source,
+		Tags:     c.defaultTags,
+	})
+	return buf.String(), err
+}
@@ -0,0 +1,204 @@
+package librato
+
+import (
+	"regexp"
+	"sort"
+)
+
+// TopNRule limits the sources (or values of a tag) a metric is submitted with in each
+// flush to the N busiest ones. Measurements of the remaining sources are rolled up into
+// a single series with the source (or tag value) set to OtherSeries. See WithTopN().
+type TopNRule struct {
+	// Name is matched against metric names. A nil expression matches every metric.
+	Name *regexp.Regexp
+	// N is the number of sources to keep per metric.
+	N int
+	// Tag selects a tag to limit instead of the source, in tagged mode.
+	Tag string
+}
+
+// topNKey identifies a series rolled up by a TopNRule.
+type topNKey struct {
+	kind MetricKind
+	name string
+}
+
+// apply rolls up the measurements beyond the top N. Sources are ranked by their
+// number of observations in this flush, breaking ties by name. Gauges are rolled
+// up into a single pre-aggregated gauge per metric, and counters into the sum of
+// the latest value of each rolled up source. Measurements pushed with a Delivery
+// and non-numeric values are never rolled up.
+func (r *TopNRule) apply(ms []Measurement) []Measurement {
+	if r.N <= 0 {
+		return ms
+	}
+
+	volumes := make(map[topNKey]map[string]int64)
+	for i := range ms {
+		m := &ms[i]
+		if !r.matches(m) {
+			continue
+		}
+		k := topNKey{m.Kind, m.Name}
+		if volumes[k] == nil {
+			volumes[k] = make(map[string]int64)
+		}
+		volumes[k][r.dimension(m)] += observations(m)
+	}
+
+	// Find the dimensions to keep for each metric over the limit.
+	keep := make(map[topNKey]map[string]bool)
+	for k, byDim := range volumes {
+		if len(byDim) <= r.N {
+			continue
+		}
+		dims := make([]string, 0, len(byDim))
+		for d := range byDim {
+			dims = append(dims, d)
+		}
+		sort.Slice(dims, func(i, j int) bool {
+			if byDim[dims[i]] != byDim[dims[j]] {
+				return byDim[dims[i]] > byDim[dims[j]]
+			}
+			return dims[i] < dims[j]
+		})
+		keep[k] = make(map[string]bool, r.N)
+		for _, d := range dims[:r.N] {
+			keep[k][d] = true
+		}
+	}
+	if len(keep) == 0 {
+		return ms
+	}
+
+	out := ms[:0:0]
+	rollups := make(map[topNKey]*rollup)
+	var order []topNKey
+	for _, m := range ms {
+		k := topNKey{m.Kind, m.Name}
+		kept, limited := keep[k]
+		if !limited || !r.matches(&m) || kept[r.dimension(&m)] || m.ack != nil {
+			out = append(out, m)
+			continue
+		}
+		ru, ok := rollups[k]
+		if !ok {
+			ru = &rollup{lastCounters: make(map[string]Measurement)}
+			rollups[k] = ru
+			order = append(order, k)
+		}
+		if !ru.add(m, r.dimension(&m)) {
+			out = append(out, m)
+		}
+	}
+	for _, k := range order {
+		if m, ok := rollups[k].measurement(r); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (r *TopNRule) matches(m *Measurement) bool {
+	if r.Tag != "" {
+		if _, ok := m.Tags[r.Tag]; !ok {
+			return false
+		}
+	}
+	return r.Name == nil || r.Name.MatchString(m.Name)
+}
+
+func (r *TopNRule) dimension(m *Measurement) string {
+	if r.Tag != "" {
+		return m.Tags[r.Tag]
+	}
+	return m.Source
+}
+
+// observations returns the number of observations m represents.
+func observations(m *Measurement) int64 {
+	if m.Count != nil {
+		return *m.Count
+	}
+	return 1
+}
+
+// rollup accumulates measurements of a single metric beyond the top N.
+type rollup struct {
+	first Measurement
+
+	summary     gaugeSummary // of gauges
+	measureTime int64
+	init        bool
+
+	// Counters, by dimension.
+	lastCounters map[string]Measurement
+}
+
+// add adds m to the rollup and reports whether it could be rolled up.
+func (ru *rollup) add(m Measurement, dim string) bool {
+	if m.Kind == KindCounter {
+		if _, ok := toFloat64(m.Value); !ok {
+			return false
+		}
+		if last, ok := ru.lastCounters[dim]; !ok || m.MeasureTime >= last.MeasureTime {
+			ru.lastCounters[dim] = m
+		}
+	} else {
+		s, ok := summarize(&m)
+		if !ok {
+			return false
+		}
+		// The sum of squares is only meaningful if every rolled up gauge has one.
+		ru.summary.merge(s)
+	}
+
+	if !ru.init {
+		ru.first = m
+		ru.init = true
+	}
+	if m.MeasureTime > ru.measureTime {
+		ru.measureTime = m.MeasureTime
+	}
+	return true
+}
+
+// measurement returns the rolled up measurement, if anything was added.
+func (ru *rollup) measurement(r *TopNRule) (Measurement, bool) {
+	if !ru.init {
+		return Measurement{}, false
+	}
+
+	m := Measurement{
+		Kind:        ru.first.Kind,
+		Name:        ru.first.Name,
+		Source:      ru.first.Source,
+		MeasureTime: ru.measureTime,
+	}
+	if r.Tag != "" {
+		m.Tags = make(map[string]string, len(ru.first.Tags))
+		for k, v := range ru.first.Tags {
+			m.Tags[k] = v
+		}
+		m.Tags[r.Tag] = OtherSeries
+	} else {
+		m.Source = OtherSeries
+	}
+
+	if m.Kind == KindCounter {
+		var total float64
+		for _, last := range ru.lastCounters {
+			v, _ := toFloat64(last.Value)
+			total += v
+		}
+		m.Value = total
+		return m, true
+	}
+
+	s := ru.summary
+	m.Count, m.Sum, m.Min, m.Max = &s.count, &s.sum, &s.min, &s.max
+	if !s.noSumSquares {
+		m.SumSquares = &s.sumSquares
+	}
+	return m, true
+}
@@ -0,0 +1,57 @@
+package librato
+
+import (
+	"context"
+)
+
+// Tracer starts spans around flushes and API requests, see WithTracer(). It's shaped so
+// that an OpenTelemetry tracer can be plugged in with a thin adapter, without this
+// package depending on it:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, librato.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+// where otelSpan converts attributes with attribute.String(), attribute.Int() and so on.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	// SetAttribute sets an attribute of the span. Values are strings, ints or bools.
+	SetAttribute(key string, value interface{})
+	// RecordError marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Names of spans and their attributes.
+const (
+	SpanFlush   = "librato.flush"
+	SpanRequest = "librato.request"
+
+	AttrGauges     = "librato.gauges"
+	AttrCounters   = "librato.counters"
+	AttrAttempts   = "librato.attempts"
+	AttrMethod     = "http.method"
+	AttrURL        = "http.url"
+	AttrStatusCode = "http.status_code"
+)
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) RecordError(error)                {}
+func (noopSpan) End()                             {}
+
+// startSpan starts a span with the configured tracer, if any.
+func (c *TimeCollatedClient) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if c.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return c.tracer.Start(ctx, name)
+}
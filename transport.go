@@ -0,0 +1,59 @@
+package librato
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportConfig tunes connection reuse of the client's HTTP transport.
+// Zero values keep the defaults of http.DefaultTransport. See WithTransport().
+type TransportConfig struct {
+	// MaxIdleConns limits idle connections across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits idle connections to the API. The default of 2 is
+	// too low to reuse connections with more than 2 flush workers.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits all connections to the API, idle or not.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long idle connections are kept open.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive time.Duration
+}
+
+// newTransport returns a copy of http.DefaultTransport with cfg applied.
+func (cfg TransportConfig) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.KeepAlive > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: cfg.KeepAlive}
+		t.DialContext = dialer.DialContext
+	}
+	return t
+}
+
+// acquire blocks until a request may be sent, or until req's context is done.
+// The returned function must be called once the request is done.
+func (c *TimeCollatedClient) acquire(req *http.Request) (release func(), err error) {
+	if c.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.inFlight <- struct{}{}:
+		return func() { <-c.inFlight }, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
@@ -0,0 +1,217 @@
+package librato
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Librato limits metric names and sources to 255 characters of A-Za-z0-9.:-_
+// http://api-docs-archive.librato.com/#create-a-metric
+const maxNameLength = 255
+
+// ValidationMode decides what happens to measurements with invalid names or sources.
+// See WithValidation().
+type ValidationMode int
+
+const (
+	// ValidateSanitize replaces invalid characters with underscores and truncates
+	// long names and sources. It's the default.
+	ValidateSanitize ValidationMode = iota
+	// ValidateReject drops invalid measurements and reports a *ValidationError.
+	ValidateReject
+	// ValidateOff sends measurements as they are. Librato rejects the whole batch
+	// if any of them is invalid.
+	ValidateOff
+	// ValidateStrict rejects invalid names and sources like ValidateReject, and also
+	// checks values, aggregates and tags against Librato's documented constraints.
+	// Batches are checked again right before they're sent, and fail without a request
+	// if any measurement is invalid, instead of being rejected by Librato with a 400.
+	ValidateStrict
+)
+
+// NonFinitePolicy decides what happens to NaN and infinite values. See WithNonFinite().
+type NonFinitePolicy int
+
+const (
+	// NonFiniteDrop drops measurements with NaN or infinite values and reports
+	// a *ValidationError. It's the default.
+	NonFiniteDrop NonFinitePolicy = iota
+	// NonFiniteClamp replaces infinite values with the largest (or smallest) float64 and
+	// reports a *ValidationError. Measurements with NaN values are still dropped.
+	NonFiniteClamp
+	// NonFiniteAllow sends measurements as they are. Librato rejects the whole batch
+	// if any of them has a non-finite value.
+	NonFiniteAllow
+)
+
+// TimestampPolicy decides what happens to measurements with a measure_time outside the
+// window accepted by Librato. See WithTimestampWindow().
+type TimestampPolicy int
+
+const (
+	// TimestampRestamp sets the measure_time of such measurements to the flush time.
+	TimestampRestamp TimestampPolicy = iota
+	// TimestampDrop drops them.
+	TimestampDrop
+)
+
+// ValidationError is reported for dropped measurements. See WithErrorHandler().
+type ValidationError struct {
+	Name   string
+	Source string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid measurement %q (source %q): %s", e.Name, e.Source, e.Reason)
+}
+
+// validate checks the name and source of m, sanitizing them if needed.
+// It returns a non-nil error if the measurement must be dropped.
+func (c *TimeCollatedClient) validate(m *Measurement) error {
+	if c.validation == ValidateOff {
+		return nil
+	}
+	if m.Name == "" {
+		return &ValidationError{Name: m.Name, Source: m.Source, Reason: "name is empty"}
+	}
+
+	if c.validation == ValidateSanitize {
+		m.Name = sanitize(m.Name)
+		m.Source = sanitize(m.Source)
+		return nil
+	}
+
+	if !validName(m.Name) {
+		return &ValidationError{Name: m.Name, Source: m.Source, Reason: "name must be at most 255 characters of A-Za-z0-9.:-_"}
+	}
+	if m.Source != "" && !validName(m.Source) {
+		return &ValidationError{Name: m.Name, Source: m.Source, Reason: "source must be at most 255 characters of A-Za-z0-9.:-_"}
+	}
+	if c.validation == ValidateStrict {
+		return checkStrict(m)
+	}
+	return nil
+}
+
+func validName(s string) bool {
+	if len(s) > maxNameLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !validChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitize replaces invalid characters in a name or source with underscores
+// and truncates it to the maximum length.
+func sanitize(s string) string {
+	if validName(s) {
+		return s
+	}
+
+	b := []byte(s)
+	if len(b) > maxNameLength {
+		b = b[:maxNameLength]
+	}
+	for i := range b {
+		if !validChar(b[i]) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func validChar(c byte) bool {
+	return c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' ||
+		c == '.' || c == ':' || c == '-' || c == '_'
+}
+
+// checkFinite applies the non-finite policy to the value and aggregates (e.g. "sum") of m.
+// It returns a non-nil error if there were non-finite values, and false if m must be dropped.
+func (c *TimeCollatedClient) checkFinite(m *Measurement) (bool, error) {
+	if c.nonFinite == NonFiniteAllow {
+		return true, nil
+	}
+
+	keep, found := true, false
+	check := func(v interface{}) interface{} {
+		f, ok := v.(float64)
+		if !ok {
+			if f32, ok32 := v.(float32); ok32 {
+				f, ok = float64(f32), true
+			}
+		}
+		if !ok || !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return v
+		}
+
+		found = true
+		switch {
+		case c.nonFinite == NonFiniteDrop || math.IsNaN(f):
+			keep = false
+		case f > 0:
+			return math.MaxFloat64
+		default:
+			return -math.MaxFloat64
+		}
+		return v
+	}
+
+	if m.Value != nil {
+		m.Value = check(m.Value)
+	}
+	for _, f := range []*float64{m.Sum, m.Min, m.Max, m.SumSquares} {
+		if f != nil {
+			*f = check(*f).(float64)
+		}
+	}
+
+	if !found {
+		return true, nil
+	}
+	reason := "value is not finite, clamped"
+	if !keep {
+		reason = "value is not finite, dropped"
+	}
+	return keep, &ValidationError{Name: m.Name, Source: m.Source, Reason: reason}
+}
+
+// checkTimestamps applies the timestamp window to measurements right before a flush
+// at the given time, returning the ones that should be sent.
+func (c *TimeCollatedClient) checkTimestamps(ms []Measurement, now time.Time) []Measurement {
+	if c.maxAge <= 0 && c.maxFuture <= 0 {
+		return ms
+	}
+
+	oldest, newest := int64(math.MinInt64), int64(math.MaxInt64)
+	if c.maxAge > 0 {
+		oldest = now.Add(-c.maxAge).Unix()
+	}
+	if c.maxFuture > 0 {
+		newest = now.Add(c.maxFuture).Unix()
+	}
+
+	kept := ms[:0]
+	for _, m := range ms {
+		if m.MeasureTime >= oldest && m.MeasureTime <= newest {
+			kept = append(kept, m)
+			continue
+		}
+
+		if c.timestampPolicy == TimestampDrop {
+			c.reportError(&ValidationError{Name: m.Name, Source: m.Source, Reason: fmt.Sprintf("measure_time %d out of range, dropped", m.MeasureTime)})
+			continue
+		}
+		c.reportError(&ValidationError{Name: m.Name, Source: m.Source, Reason: fmt.Sprintf("measure_time %d out of range, restamped", m.MeasureTime)})
+		m.MeasureTime = now.Unix()
+		kept = append(kept, m)
+	}
+	return kept
+}
@@ -0,0 +1,41 @@
+package librato
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// DefaultUserAgent is sent with every request, unless overridden with WithHeader().
+// It includes the version of this module when the binary was built with module support.
+var DefaultUserAgent = userAgent()
+
+func userAgent() string {
+	product := "librato-go"
+	if v := moduleVersion(); v != "" {
+		product += "/" + v
+	}
+	return product + " (" + runtime.Version() + "; " + runtime.GOOS + "/" + runtime.GOARCH + ")"
+}
+
+// moduleVersion returns the version of this module from the build info, or "" if
+// it's unknown, e.g. in GOPATH mode or when built from a local checkout.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	mod := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/dcelasun/librato" {
+			mod = dep
+			break
+		}
+	}
+	if mod.Path != "github.com/dcelasun/librato" || mod.Version == "(devel)" {
+		return ""
+	}
+	if mod.Replace != nil {
+		return mod.Replace.Version
+	}
+	return mod.Version
+}